@@ -79,6 +79,8 @@ func FilesystemToState(v params.Filesystem) (names.FilesystemTag, state.Filesyst
 		v.Info.Size,
 		"", // pool is set by state
 		v.Info.FilesystemId,
+		v.Info.UUID,
+		v.Info.Label,
 	}, nil
 }
 
@@ -108,6 +110,8 @@ func FilesystemInfoFromState(info state.FilesystemInfo) params.FilesystemInfo {
 		info.FilesystemId,
 		info.Pool,
 		info.Size,
+		info.UUID,
+		info.Label,
 	}
 }
 
@@ -823,7 +823,7 @@ func (s *StorageProvisionerAPI) FilesystemAttachmentParams(
 			filesystemId = filesystemInfo.FilesystemId
 			pool = filesystemInfo.Pool
 		}
-		providerType, _, err := storagecommon.StoragePoolConfig(pool, s.poolManager, s.registry)
+		providerType, cfg, err := storagecommon.StoragePoolConfig(pool, s.poolManager, s.registry)
 		if err != nil {
 			return params.FilesystemAttachmentParams{}, errors.Trace(err)
 		}
@@ -853,6 +853,7 @@ func (s *StorageProvisionerAPI) FilesystemAttachmentParams(
 			// parts of the codebase.
 			location,
 			readOnly,
+			cfg.Attrs(),
 		}, nil
 	}
 	for i, arg := range args.Ids {
@@ -34,10 +34,11 @@ type provisionerSuite struct {
 	// TODO(wallyworld) remove JujuConnSuite
 	jujutesting.JujuConnSuite
 
-	factory    *factory.Factory
-	resources  *common.Resources
-	authorizer *apiservertesting.FakeAuthorizer
-	api        *storageprovisioner.StorageProvisionerAPI
+	factory     *factory.Factory
+	resources   *common.Resources
+	authorizer  *apiservertesting.FakeAuthorizer
+	poolManager poolmanager.PoolManager
+	api         *storageprovisioner.StorageProvisionerAPI
 }
 
 func (s *provisionerSuite) SetUpTest(c *gc.C) {
@@ -53,6 +54,7 @@ func (s *provisionerSuite) SetUpTest(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 	registry := stateenvirons.NewStorageProviderRegistry(env)
 	pm := poolmanager.New(state.NewStateSettings(s.State), registry)
+	s.poolManager = pm
 
 	s.authorizer = &apiservertesting.FakeAuthorizer{
 		Tag:        names.NewMachineTag("0"),
@@ -578,6 +580,33 @@ func (s *provisionerSuite) TestFilesystemAttachmentParams(c *gc.C) {
 	})
 }
 
+func (s *provisionerSuite) TestFilesystemAttachmentParamsPoolAttributes(c *gc.C) {
+	_, err := s.poolManager.Create("fancy", "machinescoped", map[string]interface{}{
+		"mount-options": "noatime",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	s.factory.MakeMachine(c, &factory.MachineParams{
+		InstanceId: instance.Id("inst-id"),
+		Filesystems: []state.MachineFilesystemParams{{
+			Filesystem: state.FilesystemParams{Pool: "fancy", Size: 1024},
+			Attachment: state.FilesystemAttachmentParams{Location: "/srv"},
+		}},
+	})
+
+	results, err := s.api.FilesystemAttachmentParams(params.MachineStorageIds{
+		Ids: []params.MachineStorageId{{
+			MachineTag:    "machine-0",
+			AttachmentTag: "filesystem-0-0",
+		}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results.Results, gc.HasLen, 1)
+	c.Assert(results.Results[0].Error, gc.IsNil)
+	c.Assert(results.Results[0].Result.Attributes, jc.DeepEquals, map[string]interface{}{
+		"mount-options": "noatime",
+	})
+}
+
 func (s *provisionerSuite) TestSetVolumeAttachmentInfo(c *gc.C) {
 	s.setupVolumes(c)
 
@@ -306,6 +306,10 @@ type FilesystemInfo struct {
 	Pool string `json:"pool"`
 	// Size is the size of the filesystem in MiB.
 	Size uint64 `json:"size"`
+	// UUID is the UUID of the filesystem, if known.
+	UUID string `json:"uuid,omitempty"`
+	// Label is the label of the filesystem, if it has one.
+	Label string `json:"label,omitempty"`
 }
 
 // Filesystems describes a set of storage filesystems in the model.
@@ -352,6 +356,10 @@ type FilesystemAttachmentParams struct {
 	Provider      string `json:"provider"`
 	MountPoint    string `json:"mount-point,omitempty"`
 	ReadOnly      bool   `json:"read-only,omitempty"`
+	// Attributes holds the attributes of the storage pool
+	// of the filesystem. Juju controllers older than 2.2
+	// do not populate this field, so it may be omitted.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
 }
 
 // FilesystemAttachmentResult holds the details of a single filesystem attachment,
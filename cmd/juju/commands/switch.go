@@ -5,11 +5,19 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/juju/cmd"
 	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+	"github.com/juju/utils"
 
+	jujucmd "github.com/juju/juju/cmd"
 	"github.com/juju/juju/cmd/modelcmd"
 	"github.com/juju/juju/juju/osenv"
 	"github.com/juju/juju/jujuclient"
@@ -20,6 +28,9 @@ func newSwitchCommand() cmd.Command {
 		Store: jujuclient.NewFileClientStore(),
 	}
 	cmd.RefreshModels = cmd.CommandBase.RefreshModels
+	cmd.ReadPreviousTarget = readPreviousSwitchTarget
+	cmd.WritePreviousTarget = writePreviousSwitchTarget
+	cmd.ProbeAPI = probeAPIEndpoints
 	return modelcmd.WrapBase(cmd)
 }
 
@@ -27,8 +38,33 @@ type switchCommand struct {
 	modelcmd.CommandBase
 	RefreshModels func(jujuclient.ClientStore, string) error
 
-	Store  jujuclient.ClientStore
-	Target string
+	// ReadPreviousTarget and WritePreviousTarget read and write
+	// the target that was current before the last switch, so
+	// that "juju switch -" can return to it.
+	ReadPreviousTarget  func() (string, error)
+	WritePreviousTarget func(string) error
+
+	// ProbeAPI returns an error if none of the given
+	// API endpoints can be connected to.
+	ProbeAPI func(endpoints []string) error
+
+	Store     jujuclient.ClientStore
+	Target    string
+	List      bool
+	AssumeYes bool
+	Check     bool
+
+	// ControllerOnly restricts the target to naming a controller;
+	// it is never resolved to a model.
+	ControllerOnly bool
+
+	// ControllerName and ModelName hold the values of the
+	// --controller and --model flags, which may be used instead
+	// of the positional target to say exactly what is meant.
+	ControllerName string
+	ModelName      string
+
+	out cmd.Output
 }
 
 var usageSummary = `
@@ -42,6 +78,24 @@ controller by that name and switches to it, and if it's not found it tries
 to switch to a model within current controller. mycontroller: switches to
 default model in mycontroller, :mymodel switches to mymodel in current
 controller and mycontroller:mymodel switches to mymodel on mycontroller.
+Controller and model names may be abbreviated to any prefix that
+identifies exactly one controller or model.
+The special argument - switches back to the controller or model that
+was current before the last switch.
+The --format option reports the previous and new controller or model
+as structured yaml or json on stdout, instead of the usual message.
+Switching to a controller marked with "confirm-switch: true" in
+controllers.yaml asks for confirmation first, unless --yes is specified.
+The --list option prints every controller, and every model known for the
+current controller, in the form accepted as an argument. The current
+controller and model are marked with an asterisk.
+The --check option tests whether the API of the new controller can be
+reached, and warns if it cannot. The switch is made regardless.
+The --controller-only option treats the argument as a controller name
+only; if no controller matches, the command fails rather than switching
+to a model of that name.
+The --controller and --model options may be used instead of the
+argument to name the controller and model to switch to explicitly.
 The `[1:] + "`juju models`" + ` command can be used to determine the active model
 (of any controller). An asterisk denotes it.
 
@@ -52,6 +106,12 @@ Examples:
     juju switch mycontroller:mymodel
     juju switch mycontroller:
     juju switch :mymodel
+    juju switch -
+    juju switch --list
+    juju switch --check mycontroller
+    juju switch --controller-only mycontroller
+    juju switch --controller mycontroller --model mymodel
+    juju switch --model mymodel
 
 See also: 
     controllers
@@ -61,19 +121,66 @@ See also:
 func (c *switchCommand) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "switch",
-		Args:    "[<controller>|<model>|<controller>:|:<model>|<controller>:<model>]",
+		Args:    "[<controller>|<model>|<controller>:|:<model>|<controller>:<model>|-]",
 		Purpose: usageSummary,
 		Doc:     usageDetails,
 	}
 }
 
+// SetFlags implements cmd.Command.SetFlags.
+func (c *switchCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.CommandBase.SetFlags(f)
+	f.BoolVar(&c.List, "list", false, "List the controllers and models that can be switched to")
+	f.BoolVar(&c.AssumeYes, "y", false, "Do not prompt for confirmation")
+	f.BoolVar(&c.AssumeYes, "yes", false, "")
+	f.BoolVar(&c.Check, "check", false, "Warn if the API of the new controller cannot be reached")
+	f.BoolVar(&c.ControllerOnly, "controller-only", false, "Only switch to a controller, never to a model")
+	f.StringVar(&c.ControllerName, "controller", "", "Controller to switch to")
+	f.StringVar(&c.ModelName, "model", "", "Model to switch to")
+	c.out.AddFlags(f, "simple", map[string]cmd.Formatter{
+		// The simple format is never written through c.out;
+		// the change is reported on stderr as it always has been.
+		"simple": cmd.FormatSmart,
+		"json":   cmd.FormatJson,
+		"yaml":   cmd.FormatYaml,
+	})
+}
+
 func (c *switchCommand) Init(args []string) error {
 	var err error
 	c.Target, err = cmd.ZeroOrOneArgs(args)
-	return err
+	if err != nil {
+		return err
+	}
+	if c.ControllerName != "" || c.ModelName != "" {
+		if c.Target != "" {
+			return errors.New("cannot specify a target with --controller or --model")
+		}
+		if strings.Contains(c.ControllerName, ":") {
+			return errors.Errorf("invalid controller name %q", c.ControllerName)
+		}
+		switch {
+		case c.ModelName == "":
+			c.Target = c.ControllerName + ":"
+		case strings.Contains(c.ModelName, ":"):
+			if c.ControllerName != "" {
+				return errors.New("cannot specify a controller with both --controller and --model")
+			}
+			c.Target = c.ModelName
+		default:
+			c.Target = c.ControllerName + ":" + c.ModelName
+		}
+	}
+	if c.List && c.Target != "" {
+		return errors.New("cannot specify a target with --list")
+	}
+	if c.ControllerOnly && strings.Contains(strings.TrimSuffix(c.Target, ":"), ":") {
+		return errors.New("cannot specify a model with --controller-only")
+	}
+	return nil
 }
 
-func (c *switchCommand) Run(ctx *cmd.Context) (resultErr error) {
+func (c *switchCommand) Run(ctx *cmd.Context) error {
 	store := modelcmd.QualifyingClientStore{c.Store}
 
 	// Get the current name for logging the transition or printing
@@ -84,29 +191,29 @@ func (c *switchCommand) Run(ctx *cmd.Context) (resultErr error) {
 	} else if err != nil {
 		return errors.Trace(err)
 	}
+	if c.List {
+		return errors.Trace(listSwitchTargets(ctx, store, currentControllerName))
+	}
 	if c.Target == "" {
 		currentName, err := c.name(store, currentControllerName, true)
 		if err != nil {
 			return errors.Trace(err)
 		}
 		if currentName == "" {
-			return errors.New("no currently specified model")
+			// Like "git branch" in a new repository, there
+			// is simply nothing to report; this is not an error.
+			ctx.Infof("no current controller")
+			return nil
 		}
 		fmt.Fprintf(ctx.Stdout, "%s\n", currentName)
 		return nil
 	}
-	currentName, err := c.name(store, currentControllerName, false)
+	currentModel, err := currentModelName(store, currentControllerName)
 	if err != nil {
 		return errors.Trace(err)
 	}
-
-	var newName string
-	defer func() {
-		if resultErr != nil {
-			return
-		}
-		logSwitch(ctx, currentName, &newName)
-	}()
+	currentName := formatName(currentControllerName, currentModel, false)
+	currentTarget := switchTarget(currentControllerName, currentModel)
 
 	// Switch is an alternative way of dealing with environments than using
 	// the JUJU_MODEL environment setting, and as such, doesn't play too well.
@@ -116,82 +223,516 @@ func (c *switchCommand) Run(ctx *cmd.Context) (resultErr error) {
 		return errors.Errorf("cannot switch when JUJU_MODEL is overriding the model (set to %q)", model)
 	}
 
+	// Names may be abbreviated to an unambiguous prefix, except
+	// when switching back to the previous target, which is recorded
+	// in full.
+	matchPrefix := true
+	if c.Target == "-" {
+		matchPrefix = false
+		previousTarget, err := c.ReadPreviousTarget()
+		if err != nil {
+			return errors.Annotate(err, "reading previous controller/model")
+		}
+		if previousTarget == "" {
+			return errors.New("no previous controller or model to switch to")
+		}
+		c.Target = previousTarget
+	}
+
+	newControllerName, newModelName, err := c.switchTo(ctx, store, currentControllerName, currentModel, c.Target, matchPrefix)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if c.Check {
+		c.checkAPI(ctx, store, newControllerName)
+	}
+	newName := formatName(newControllerName, newModelName, false)
+	if c.out.Name() == "simple" {
+		logSwitch(ctx, currentName, newName)
+	} else {
+		result := switchResult{
+			Previous: formatName(currentControllerName, currentModel, true),
+			Current:  formatName(newControllerName, newModelName, true),
+			Type:     "controller",
+			Changed:  newName != currentName,
+		}
+		if newModelName != "" {
+			result.Type = "model"
+		}
+		if err := c.out.Write(ctx, result); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if newName != currentName && currentTarget != "" {
+		// The switch has already happened, so failing to record
+		// where we came from only affects "juju switch -".
+		if err := c.WritePreviousTarget(currentTarget); err != nil {
+			logger.Warningf("cannot record previous controller/model: %v", err)
+		}
+	}
+	return nil
+}
+
+// switchResult describes the outcome of a switch, for
+// machine-readable output formats.
+type switchResult struct {
+	Previous string `yaml:"previous" json:"previous"`
+	Current  string `yaml:"current" json:"current"`
+	Type     string `yaml:"type" json:"type"`
+	Changed  bool   `yaml:"changed" json:"changed"`
+}
+
+// switchTo switches to the specified target, returning the names of the
+// new current controller and model. If matchPrefix is true and the target
+// does not exactly name a controller or model, it may be an unambiguous
+// prefix of one.
+func (c *switchCommand) switchTo(
+	ctx *cmd.Context,
+	store modelcmd.QualifyingClientStore,
+	currentControllerName, currentModel string,
+	target string,
+	matchPrefix bool,
+) (string, string, error) {
 	// If the target identifies a controller, or we want a controller explicitly,
 	// then set that as the current controller.
-	var newControllerName = c.Target
-	var forceController = false
-	if c.Target[len(c.Target)-1] == ':' {
+	var newControllerName = target
+	var forceController = c.ControllerOnly
+	if target[len(target)-1] == ':' {
 		forceController = true
-		newControllerName = c.Target[:len(c.Target)-1]
+		newControllerName = target[:len(target)-1]
 	}
-	if _, err = store.ControllerByName(newControllerName); err == nil {
+	details, err := store.ControllerByName(newControllerName)
+	if err == nil {
 		if newControllerName == currentControllerName {
-			newName = currentName
-			return nil
-		} else {
-			newName, err = c.name(store, newControllerName, false)
-			if err != nil {
-				return errors.Trace(err)
+			return currentControllerName, currentModel, nil
+		}
+		if !forceController && currentControllerName != "" {
+			// A model in the current controller with the same
+			// name as the target would otherwise be unreachable
+			// by its bare name, so make the user choose. Any
+			// error means that there is no such model.
+			if _, err := store.ModelByName(currentControllerName, target); err == nil {
+				return "", "", errors.Errorf(
+					"%q is ambiguous: it is both a controller and a model on controller %q; "+
+						"use \"%s:%s\" for the model, or --controller %s for the controller",
+					target, currentControllerName, currentControllerName, target, target,
+				)
 			}
-			return errors.Trace(store.SetCurrentController(newControllerName))
 		}
-	} else if !errors.IsNotFound(err) || forceController {
-		return errors.Trace(err)
+		if err := c.confirmSwitch(ctx, newControllerName, details); err != nil {
+			return "", "", errors.Trace(err)
+		}
+		modelName, err := currentModelName(store, newControllerName)
+		if err != nil {
+			return "", "", errors.Trace(err)
+		}
+		return newControllerName, modelName, errors.Trace(store.SetCurrentController(newControllerName))
+	} else if !errors.IsNotFound(err) {
+		return "", "", errors.Trace(err)
+	} else if forceController {
+		if !matchPrefix {
+			return "", "", errors.Trace(err)
+		}
+		candidates, cerr := controllerCandidates(store, newControllerName)
+		if cerr != nil {
+			return "", "", errors.Trace(cerr)
+		}
+		return c.switchToCandidate(ctx, store, currentControllerName, currentModel, target, candidates, func() error {
+			return errors.Trace(err)
+		})
 	}
 
 	// The target is not a controller, so check for a model with
 	// the given name. The name can be qualified with the controller
 	// name (<controller>:<model>), or unqualified; in the latter
 	// case, the model must exist in the current controller.
-	newControllerName, modelName := modelcmd.SplitModelName(c.Target)
+	unqualified := !strings.Contains(target, ":")
+	newControllerName, modelName := modelcmd.SplitModelName(target)
+	requestedModelName := modelName
 	if newControllerName != "" {
-		if _, err = store.ControllerByName(newControllerName); err != nil {
-			return errors.Trace(err)
+		details, err := store.ControllerByName(newControllerName)
+		if errors.IsNotFound(err) && matchPrefix {
+			candidates, cerr := controllerCandidates(store, newControllerName)
+			if cerr != nil {
+				return "", "", errors.Trace(cerr)
+			}
+			// Retain the model part of the target for each
+			// of the matching controllers.
+			for i, candidate := range candidates {
+				candidates[i] = candidate + modelName
+			}
+			return c.switchToCandidate(ctx, store, currentControllerName, currentModel, target, candidates, func() error {
+				return errors.Trace(err)
+			})
+		} else if err != nil {
+			return "", "", errors.Trace(err)
+		}
+		if newControllerName != currentControllerName {
+			if err := c.confirmSwitch(ctx, newControllerName, details); err != nil {
+				return "", "", errors.Trace(err)
+			}
 		}
 	} else {
 		if currentControllerName == "" {
-			return unknownSwitchTargetError(c.Target)
+			var candidates []string
+			if matchPrefix && unqualified {
+				candidates, err = controllerCandidates(store, target)
+				if err != nil {
+					return "", "", errors.Trace(err)
+				}
+			}
+			return c.switchToCandidate(ctx, store, currentControllerName, currentModel, target, candidates, func() error {
+				return unknownSwitchTargetError(store, target, true, "", "")
+			})
 		}
 		newControllerName = currentControllerName
 	}
 	modelName, err = store.QualifiedModelName(newControllerName, modelName)
 	if err != nil {
-		return errors.Trace(err)
+		return "", "", errors.Trace(err)
 	}
-	newName = modelcmd.JoinModelName(newControllerName, modelName)
-
-	err = store.SetCurrentModel(newControllerName, modelName)
+	// Check that the model exists before making any changes, so that
+	// the controller can be made current before the model: if that
+	// fails, the new controller's current model is left untouched.
+	_, err = store.ModelByName(newControllerName, modelName)
 	if errors.IsNotFound(err) {
 		// The model isn't known locally, so we must query the controller.
 		if err := c.RefreshModels(store, newControllerName); err != nil {
-			return errors.Annotate(err, "refreshing models cache")
+			return "", "", errors.Annotate(err, "refreshing models cache")
 		}
-		err := store.SetCurrentModel(newControllerName, modelName)
+		_, err := store.ModelByName(newControllerName, modelName)
 		if errors.IsNotFound(err) {
-			return unknownSwitchTargetError(c.Target)
+			var candidates []string
+			if matchPrefix {
+				candidates, err = modelCandidates(store, newControllerName, modelName)
+				if err != nil {
+					return "", "", errors.Trace(err)
+				}
+				if !jujuclient.IsQualifiedModelName(requestedModelName) {
+					// The user does not own a model by that name,
+					// but other users might.
+					owned, err := ownerCandidates(store, newControllerName, requestedModelName)
+					if err != nil {
+						return "", "", errors.Trace(err)
+					}
+					candidates = append(candidates, owned...)
+				}
+				if unqualified {
+					controllers, err := controllerCandidates(store, target)
+					if err != nil {
+						return "", "", errors.Trace(err)
+					}
+					candidates = append(controllers, candidates...)
+				}
+			}
+			return c.switchToCandidate(ctx, store, currentControllerName, currentModel, target, candidates, func() error {
+				return unknownSwitchTargetError(store, target, unqualified, newControllerName, modelName)
+			})
 		} else if err != nil {
-			return errors.Trace(err)
+			return "", "", errors.Trace(err)
 		}
 	} else if err != nil {
-		return errors.Trace(err)
+		return "", "", errors.Trace(err)
 	}
 	if currentControllerName != newControllerName {
 		if err := store.SetCurrentController(newControllerName); err != nil {
-			return errors.Trace(err)
+			return "", "", errors.Trace(err)
+		}
+	}
+	if err := store.SetCurrentModel(newControllerName, modelName); err != nil {
+		if currentControllerName != newControllerName {
+			restoreCurrentController(store, currentControllerName)
 		}
+		return "", "", errors.Trace(err)
+	}
+	return newControllerName, modelName, nil
+}
+
+// restoreCurrentController makes the given controller the current
+// controller again, after a switch to a model of another controller
+// could not be completed. Failures are logged, as the switch has
+// already failed.
+func restoreCurrentController(store jujuclient.ControllerUpdater, controllerName string) {
+	if controllerName == "" {
+		// There is no way to unset the current controller.
+		logger.Warningf("cannot restore current controller: none was set")
+		return
+	}
+	if err := store.SetCurrentController(controllerName); err != nil {
+		logger.Warningf("cannot restore current controller %q: %v", controllerName, err)
+	}
+}
+
+// switchToCandidate switches to the only one of the candidate targets
+// matching the prefix given by the user. If there are no candidates,
+// the error returned by notFound is returned; if there are several,
+// the target is ambiguous.
+func (c *switchCommand) switchToCandidate(
+	ctx *cmd.Context,
+	store modelcmd.QualifyingClientStore,
+	currentControllerName, currentModel string,
+	target string,
+	candidates []string,
+	notFound func() error,
+) (string, string, error) {
+	switch len(candidates) {
+	case 0:
+		return "", "", notFound()
+	case 1:
+		return c.switchTo(ctx, store, currentControllerName, currentModel, candidates[0], false)
+	}
+	return "", "", errors.Errorf("%q is ambiguous, could be any of: %s", target, strings.Join(candidates, ", "))
+}
+
+// checkAPI warns if the API of the specified controller cannot be
+// reached. The switch has already been made, so this is not an error.
+func (c *switchCommand) checkAPI(ctx *cmd.Context, store jujuclient.ClientStore, controllerName string) {
+	details, err := store.ControllerByName(controllerName)
+	if err != nil {
+		logger.Debugf("cannot check controller %q: %v", controllerName, err)
+		return
+	}
+	if err := c.ProbeAPI(details.APIEndpoints); err != nil {
+		logger.Debugf("probing controller %q API: %v", controllerName, err)
+		fmt.Fprintf(ctx.Stderr, "switched to %s, but its API is currently unreachable\n", controllerName)
+	}
+}
+
+// apiProbeTimeout is the maximum time probeAPIEndpoints
+// waits to connect to each API endpoint.
+const apiProbeTimeout = 2 * time.Second
+
+// probeAPIEndpoints returns nil if a TCP connection can be made to any
+// of the given API endpoints, and the last connection error otherwise.
+func probeAPIEndpoints(endpoints []string) error {
+	if len(endpoints) == 0 {
+		return errors.New("no API endpoints")
+	}
+	var err error
+	for _, endpoint := range endpoints {
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", endpoint, apiProbeTimeout)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return errors.Trace(err)
+}
+
+var confirmSwitchMsg = `
+Controller %q is marked as requiring confirmation before switching to it.
+
+Really switch to %s? (y/N): `[1:]
+
+// confirmSwitch asks the user to confirm switching to the specified
+// controller, if the controller is marked as requiring it.
+func (c *switchCommand) confirmSwitch(ctx *cmd.Context, controllerName string, details *jujuclient.ControllerDetails) error {
+	if !details.ConfirmSwitch || c.AssumeYes {
+		return nil
+	}
+	fmt.Fprintf(ctx.Stderr, confirmSwitchMsg, controllerName, controllerName)
+	if err := jujucmd.UserConfirmYes(ctx); err != nil {
+		return errors.Annotate(err, "switching controller")
 	}
 	return nil
 }
 
-func unknownSwitchTargetError(name string) error {
-	return errors.Errorf("%q is not the name of a model or controller", name)
+// controllerCandidates returns the switch targets of the controllers
+// whose names start with the given prefix, in sorted order.
+func controllerCandidates(store jujuclient.ControllerGetter, prefix string) ([]string, error) {
+	controllers, err := store.AllControllers()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var candidates []string
+	for name := range controllers {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, switchTarget(name, ""))
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// modelCandidates returns the switch targets of the models of the
+// specified controller whose qualified names start with the given
+// prefix, in sorted order.
+func modelCandidates(store jujuclient.ModelGetter, controllerName, prefix string) ([]string, error) {
+	models, err := store.AllModels(controllerName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var candidates []string
+	for name := range models {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, switchTarget(controllerName, name))
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// ownerCandidates returns the switch targets for the models of the
+// specified controller, owned by any user, with the given unqualified
+// name.
+func ownerCandidates(store jujuclient.ModelGetter, controllerName, modelName string) ([]string, error) {
+	models, err := store.AllModels(controllerName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var candidates []string
+	for name := range models {
+		unqualified, _, err := jujuclient.SplitModelName(name)
+		if err != nil {
+			continue
+		}
+		if unqualified == modelName {
+			candidates = append(candidates, switchTarget(controllerName, name))
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, nil
+}
+
+// listSwitchTargets prints the names of all controllers, and of all
+// models of the current controller, marking the current ones.
+func listSwitchTargets(ctx *cmd.Context, store jujuclient.ClientStore, currentControllerName string) error {
+	controllers, err := store.AllControllers()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	controllerNames := make([]string, 0, len(controllers))
+	for name := range controllers {
+		controllerNames = append(controllerNames, name)
+	}
+	sort.Strings(controllerNames)
+	for _, name := range controllerNames {
+		printSwitchTarget(ctx, switchTarget(name, ""), name == currentControllerName)
+	}
+	if currentControllerName == "" {
+		return nil
+	}
+
+	models, err := store.AllModels(currentControllerName)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	currentModel, err := currentModelName(store, currentControllerName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelNames := make([]string, 0, len(models))
+	for name := range models {
+		modelNames = append(modelNames, name)
+	}
+	sort.Strings(modelNames)
+	for _, name := range modelNames {
+		printSwitchTarget(ctx, switchTarget(currentControllerName, name), name == currentModel)
+	}
+	return nil
+}
+
+func printSwitchTarget(ctx *cmd.Context, target string, current bool) {
+	marker := " "
+	if current {
+		marker = "*"
+	}
+	fmt.Fprintf(ctx.Stdout, "%s %s\n", marker, target)
+}
+
+// maxSuggestionDistance is the maximum edit distance between an
+// unknown switch target and the name of a controller or model for
+// the latter to be suggested in its place.
+const maxSuggestionDistance = 2
+
+// unknownSwitchTargetError returns an error indicating that the target
+// is not the name of a model or controller. If suggestControllers is
+// true, controllers with names similar to the target are suggested; if
+// controllerName is non-empty, models of that controller with names
+// similar to the (qualified) modelName are suggested.
+func unknownSwitchTargetError(
+	store jujuclient.ClientStore,
+	target string,
+	suggestControllers bool,
+	controllerName, modelName string,
+) error {
+	var suggestions []string
+	if suggestControllers {
+		controllers, err := store.AllControllers()
+		if err != nil {
+			logger.Debugf("cannot suggest controllers: %v", err)
+		}
+		for name := range controllers {
+			if editDistance(target, name) <= maxSuggestionDistance {
+				suggestions = append(suggestions, switchTarget(name, ""))
+			}
+		}
+	}
+	if controllerName != "" {
+		models, err := store.AllModels(controllerName)
+		if err != nil && !errors.IsNotFound(err) {
+			logger.Debugf("cannot suggest models: %v", err)
+		}
+		for name := range models {
+			if editDistance(modelName, name) <= maxSuggestionDistance {
+				suggestions = append(suggestions, switchTarget(controllerName, name))
+			}
+		}
+	}
+	if len(suggestions) == 0 {
+		return errors.Errorf("%q is not the name of a model or controller", target)
+	}
+	sort.Strings(suggestions)
+	return errors.Errorf(
+		"%q is not the name of a model or controller, did you mean %s?",
+		target, strings.Join(suggestions, " or "),
+	)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
 }
 
-func logSwitch(ctx *cmd.Context, oldName string, newName *string) {
-	if *newName == oldName {
+func logSwitch(ctx *cmd.Context, oldName string, newName string) {
+	if newName == oldName {
 		ctx.Infof("%s (no change)", oldName)
 	} else {
-		ctx.Infof("%s -> %s", oldName, *newName)
+		ctx.Infof("%s -> %s", oldName, newName)
 	}
 }
 
@@ -199,19 +740,68 @@ func logSwitch(ctx *cmd.Context, oldName string, newName *string) {
 // if one is set, otherwise the controller name with an indicator that it
 // is the name of a controller and not a model.
 func (c *switchCommand) name(store jujuclient.ModelGetter, controllerName string, machineReadable bool) (string, error) {
+	modelName, err := currentModelName(store, controllerName)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return formatName(controllerName, modelName, machineReadable), nil
+}
+
+// currentModelName returns the name of the current model for the specified
+// controller, or the empty string if there is none.
+func currentModelName(store jujuclient.ModelGetter, controllerName string) (string, error) {
 	if controllerName == "" {
 		return "", nil
 	}
 	modelName, err := store.CurrentModel(controllerName)
-	if err == nil {
-		return modelcmd.JoinModelName(controllerName, modelName), nil
+	if errors.IsNotFound(err) {
+		// No current account or model.
+		return "", nil
 	}
-	if !errors.IsNotFound(err) {
-		return "", errors.Trace(err)
+	return modelName, errors.Trace(err)
+}
+
+func formatName(controllerName, modelName string, machineReadable bool) string {
+	switch {
+	case controllerName == "":
+		return ""
+	case modelName != "":
+		return modelcmd.JoinModelName(controllerName, modelName)
+	case machineReadable:
+		return controllerName
 	}
-	// No current account or model.
-	if machineReadable {
-		return controllerName, nil
+	return fmt.Sprintf("%s (controller)", controllerName)
+}
+
+// switchTarget returns the argument that will switch back to the
+// specified controller and model.
+func switchTarget(controllerName, modelName string) string {
+	if controllerName == "" {
+		return ""
 	}
-	return fmt.Sprintf("%s (controller)", controllerName), nil
+	// The trailing colon ensures that a controller is chosen,
+	// even if there is a model with the same name.
+	return modelcmd.JoinModelName(controllerName, modelName)
+}
+
+func previousSwitchTargetPath() string {
+	return osenv.JujuXDGDataHomePath("previous-switch-target")
+}
+
+// readPreviousSwitchTarget returns the target recorded by
+// writePreviousSwitchTarget, or the empty string if there is none.
+func readPreviousSwitchTarget() (string, error) {
+	data, err := ioutil.ReadFile(previousSwitchTargetPath())
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Trace(err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writePreviousSwitchTarget records the target that was current
+// before a switch.
+func writePreviousSwitchTarget(target string) error {
+	return utils.AtomicWriteFile(previousSwitchTargetPath(), []byte(target+"\n"), os.FileMode(0600))
 }
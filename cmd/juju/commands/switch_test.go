@@ -5,7 +5,9 @@ package commands
 
 import (
 	"errors"
+	"net"
 	"os"
+	"strings"
 
 	"github.com/juju/cmd"
 	"github.com/juju/cmd/cmdtesting"
@@ -26,6 +28,10 @@ type SwitchSimpleSuite struct {
 	store     *jujuclient.MemStore
 	stubStore *jujuclienttesting.StubStore
 	onRefresh func()
+
+	previousTarget   string
+	writePreviousErr error
+	probeErr         error
 }
 
 var _ = gc.Suite(&SwitchSimpleSuite{})
@@ -36,6 +42,9 @@ func (s *SwitchSimpleSuite) SetUpTest(c *gc.C) {
 	s.store = jujuclient.NewMemStore()
 	s.stubStore = jujuclienttesting.WrapClientStore(s.store)
 	s.onRefresh = nil
+	s.previousTarget = ""
+	s.writePreviousErr = nil
+	s.probeErr = nil
 }
 
 func (s *SwitchSimpleSuite) refreshModels(store jujuclient.ClientStore, controllerName string) error {
@@ -47,16 +56,58 @@ func (s *SwitchSimpleSuite) refreshModels(store jujuclient.ClientStore, controll
 }
 
 func (s *SwitchSimpleSuite) run(c *gc.C, args ...string) (*cmd.Context, error) {
+	return cmdtesting.RunCommand(c, s.newCommand(), args...)
+}
+
+func (s *SwitchSimpleSuite) runWithStdin(c *gc.C, stdin string, args ...string) (*cmd.Context, error) {
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader(stdin)
+	command := s.newCommand()
+	if err := cmdtesting.InitCommand(command, args); err != nil {
+		return ctx, err
+	}
+	return ctx, command.Run(ctx)
+}
+
+func (s *SwitchSimpleSuite) newCommand() cmd.Command {
 	cmd := &switchCommand{
 		Store:         s.stubStore,
 		RefreshModels: s.refreshModels,
+		ReadPreviousTarget: func() (string, error) {
+			return s.previousTarget, nil
+		},
+		WritePreviousTarget: func(target string) error {
+			if s.writePreviousErr != nil {
+				return s.writePreviousErr
+			}
+			s.previousTarget = target
+			return nil
+		},
+		ProbeAPI: func(endpoints []string) error {
+			s.MethodCall(s, "ProbeAPI", endpoints)
+			return s.probeErr
+		},
 	}
-	return cmdtesting.RunCommand(c, modelcmd.WrapBase(cmd), args...)
+	return modelcmd.WrapBase(cmd)
 }
 
 func (s *SwitchSimpleSuite) TestNoArgs(c *gc.C) {
+	ctx, err := s.run(c)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "no current controller\n")
+}
+
+func (s *SwitchSimpleSuite) TestNoArgsNoCurrentControllerDoesNotSelectOne(c *gc.C) {
+	// Known controllers are not made current just by
+	// reporting that there is no current controller.
+	s.addController(c, "a-controller")
 	_, err := s.run(c)
-	c.Assert(err, gc.ErrorMatches, "no currently specified model")
+	c.Assert(err, jc.ErrorIsNil)
+	s.stubStore.CheckCalls(c, []testing.StubCall{
+		{"CurrentController", nil},
+	})
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "")
 }
 
 func (s *SwitchSimpleSuite) TestNoArgsCurrentController(c *gc.C) {
@@ -150,11 +201,43 @@ func (s *SwitchSimpleSuite) TestSwitchControllerToModel(c *gc.C) {
 		{"CurrentModel", []interface{}{"ctrl"}},
 		{"ControllerByName", []interface{}{"mymodel"}},
 		{"AccountDetails", []interface{}{"ctrl"}},
+		{"ModelByName", []interface{}{"ctrl", "admin/mymodel"}},
 		{"SetCurrentModel", []interface{}{"ctrl", "admin/mymodel"}},
 	})
 	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "admin/mymodel")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchControllerOnlyIgnoresModel(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	_, err := s.run(c, "--controller-only", "mymodel")
+	c.Assert(err, gc.ErrorMatches, "controller mymodel not found")
+	s.stubStore.CheckCalls(c, []testing.StubCall{
+		{"CurrentController", nil},
+		{"CurrentModel", []interface{}{"ctrl"}},
+		{"ControllerByName", []interface{}{"mymodel"}},
+		{"AllControllers", nil},
+	})
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerOnly(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	context, err := s.run(c, "--controller-only", "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "old (controller) -> new (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerOnlyWithModel(c *gc.C) {
+	_, err := s.run(c, "--controller-only", "ctrl:mymodel")
+	c.Assert(err, gc.ErrorMatches, "cannot specify a model with --controller-only")
+}
+
 func (s *SwitchSimpleSuite) TestSwitchControllerToModelDifferentController(c *gc.C) {
 	s.store.CurrentControllerName = "old"
 	s.addController(c, "new")
@@ -170,12 +253,28 @@ func (s *SwitchSimpleSuite) TestSwitchControllerToModelDifferentController(c *gc
 		{"ControllerByName", []interface{}{"new:mymodel"}},
 		{"ControllerByName", []interface{}{"new"}},
 		{"AccountDetails", []interface{}{"new"}},
-		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
 		{"SetCurrentController", []interface{}{"new"}},
+		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
 	})
 	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "admin/mymodel")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchControllerRestoresCurrentModel(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models:       map[string]jujuclient.ModelDetails{"admin/lastmodel": {}, "admin/other": {}},
+		CurrentModel: "admin/lastmodel",
+	}
+	context, err := s.run(c, "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "old (controller) -> new:admin/lastmodel\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "admin/lastmodel")
+}
+
 func (s *SwitchSimpleSuite) TestSwitchControllerSameNameAsModel(c *gc.C) {
 	s.store.CurrentControllerName = "old"
 	s.addController(c, "new")
@@ -188,7 +287,26 @@ func (s *SwitchSimpleSuite) TestSwitchControllerSameNameAsModel(c *gc.C) {
 	}
 	_, err := s.run(c, "new:mymodel")
 	c.Assert(err, jc.ErrorIsNil)
-	context, err := s.run(c, "old")
+	_, err = s.run(c, "old")
+	c.Assert(err, gc.ErrorMatches, `"old" is ambiguous: it is both a controller and a model on controller "new"; `+
+		`use "new:old" for the model, or --controller old for the controller`)
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerSameNameAsModelControllerFlag(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	s.addController(c, "old")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}, "admin/old": {}},
+	}
+	s.store.Models["old"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/somemodel": {}},
+	}
+	_, err := s.run(c, "new:mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	context, err := s.run(c, "--controller", "old")
+	c.Assert(err, jc.ErrorIsNil)
 	c.Assert(cmdtesting.Stderr(context), gc.Equals, "new:admin/mymodel -> old (controller)\n")
 }
 
@@ -223,8 +341,9 @@ func (s *SwitchSimpleSuite) TestSwitchLocalControllerToModelDifferentController(
 		{"ControllerByName", []interface{}{"new:mymodel"}},
 		{"ControllerByName", []interface{}{"new"}},
 		{"AccountDetails", []interface{}{"new"}},
-		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
 		{"SetCurrentController", []interface{}{"new"}},
+		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
 	})
 	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "admin/mymodel")
 }
@@ -245,11 +364,88 @@ func (s *SwitchSimpleSuite) TestSwitchControllerToDifferentControllerCurrentMode
 		{"ControllerByName", []interface{}{"new:mymodel"}},
 		{"ControllerByName", []interface{}{"new"}},
 		{"AccountDetails", []interface{}{"new"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
+		{"SetCurrentController", []interface{}{"new"}},
 		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
+	})
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerToModelControllerFails(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models:       map[string]jujuclient.ModelDetails{"admin/mymodel": {}, "admin/other": {}},
+		CurrentModel: "admin/other",
+	}
+	s.stubStore.SetCurrentControllerFunc = func(string) error {
+		return errors.New("disk full")
+	}
+	_, err := s.run(c, "new:mymodel")
+	c.Assert(err, gc.ErrorMatches, "disk full")
+	s.stubStore.CheckCalls(c, []testing.StubCall{
+		{"CurrentController", nil},
+		{"CurrentModel", []interface{}{"old"}},
+		{"ControllerByName", []interface{}{"new:mymodel"}},
+		{"ControllerByName", []interface{}{"new"}},
+		{"AccountDetails", []interface{}{"new"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
 		{"SetCurrentController", []interface{}{"new"}},
 	})
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "old")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "admin/other")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchControllerToModelControllerFailsNoCurrentModel(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	s.stubStore.SetCurrentControllerFunc = func(string) error {
+		return errors.New("disk full")
+	}
+	_, err := s.run(c, "new:mymodel")
+	c.Assert(err, gc.ErrorMatches, "disk full")
+	s.stubStore.CheckCalls(c, []testing.StubCall{
+		{"CurrentController", nil},
+		{"CurrentModel", []interface{}{"old"}},
+		{"ControllerByName", []interface{}{"new:mymodel"}},
+		{"ControllerByName", []interface{}{"new"}},
+		{"AccountDetails", []interface{}{"new"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
+		{"SetCurrentController", []interface{}{"new"}},
+	})
+	// The new controller had no current model, and still has none.
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "old")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerToModelModelFailsRestoresController(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	s.stubStore.SetCurrentModelFunc = func(string, string) error {
+		return errors.New("disk full")
+	}
+	_, err := s.run(c, "new:mymodel")
+	c.Assert(err, gc.ErrorMatches, "disk full")
+	s.stubStore.CheckCalls(c, []testing.StubCall{
+		{"CurrentController", nil},
+		{"CurrentModel", []interface{}{"old"}},
+		{"ControllerByName", []interface{}{"new:mymodel"}},
+		{"ControllerByName", []interface{}{"new"}},
+		{"AccountDetails", []interface{}{"new"}},
+		{"ModelByName", []interface{}{"new", "admin/mymodel"}},
+		{"SetCurrentController", []interface{}{"new"}},
+		{"SetCurrentModel", []interface{}{"new", "admin/mymodel"}},
+		{"SetCurrentController", []interface{}{"old"}},
+	})
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "old")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "")
+}
 func (s *SwitchSimpleSuite) TestSwitchToModelDifferentOwner(c *gc.C) {
 	s.store.CurrentControllerName = "same"
 	s.addController(c, "same")
@@ -266,12 +462,110 @@ func (s *SwitchSimpleSuite) TestSwitchToModelDifferentOwner(c *gc.C) {
 	c.Assert(s.store.Models["same"].CurrentModel, gc.Equals, "bianca/mymodel")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchToControllerModelDifferentOwner(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/mymodel":  {},
+			"bianca/mymodel": {},
+		},
+	}
+	context, err := s.run(c, "new:bianca/mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "old (controller) -> new:bianca/mymodel\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "bianca/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchModelFlag(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.addController(c, "mymodel")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	// The model is chosen, even though there is
+	// a controller with the same name.
+	context, err := s.run(c, "--model", "mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "ctrl (controller) -> ctrl:admin/mymodel\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "ctrl")
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "admin/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerFlag(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Models["old"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/new": {}},
+	}
+	context, err := s.run(c, "--controller", "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "old (controller) -> new (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerAndModelFlags(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	context, err := s.run(c, "--controller", "new", "--model", "mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "old (controller) -> new:admin/mymodel\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+	c.Assert(s.store.Models["new"].CurrentModel, gc.Equals, "admin/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchFlagsWithTarget(c *gc.C) {
+	_, err := s.run(c, "--model", "mymodel", "ctrl")
+	c.Assert(err, gc.ErrorMatches, "cannot specify a target with --controller or --model")
+	_, err = s.run(c, "--controller", "ctrl", "ctrl")
+	c.Assert(err, gc.ErrorMatches, "cannot specify a target with --controller or --model")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerFlagWithQualifiedModelFlag(c *gc.C) {
+	_, err := s.run(c, "--controller", "ctrl", "--model", "other:mymodel")
+	c.Assert(err, gc.ErrorMatches, "cannot specify a controller with both --controller and --model")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchToOtherOwnersModel(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"bianca/mymodel": {}},
+	}
+	context, err := s.run(c, "mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "ctrl (controller) -> ctrl:bianca/mymodel\n")
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "bianca/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchToOtherOwnersModelAmbiguous(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"bianca/mymodel": {},
+			"claire/mymodel": {},
+		},
+	}
+	_, err := s.run(c, "mymodel")
+	c.Assert(err, gc.ErrorMatches, `"mymodel" is ambiguous, could be any of: ctrl:bianca/mymodel, ctrl:claire/mymodel`)
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "")
+}
+
 func (s *SwitchSimpleSuite) TestSwitchUnknownNoCurrentController(c *gc.C) {
 	_, err := s.run(c, "unknown")
 	c.Assert(err, gc.ErrorMatches, `"unknown" is not the name of a model or controller`)
 	s.stubStore.CheckCalls(c, []testing.StubCall{
 		{"CurrentController", nil},
 		{"ControllerByName", []interface{}{"unknown"}},
+		{"AllControllers", nil},
+		{"AllControllers", nil},
 	})
 }
 
@@ -297,6 +591,48 @@ func (s *SwitchSimpleSuite) TestSwitchUnknownCurrentControllerRefreshModelsStill
 	s.CheckCallNames(c, "RefreshModels")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchUnknownSuggestsModel(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/default":    {},
+			"admin/production": {},
+		},
+	}
+	_, err := s.run(c, "defualt")
+	c.Assert(err, gc.ErrorMatches, `"defualt" is not the name of a model or controller, did you mean ctrl:admin/default\?`)
+	s.CheckCallNames(c, "RefreshModels")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchUnknownSuggestsController(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.addController(c, "staging")
+	_, err := s.run(c, "stagign")
+	c.Assert(err, gc.ErrorMatches, `"stagign" is not the name of a model or controller, did you mean staging:\?`)
+}
+
+func (s *SwitchSimpleSuite) TestSwitchUnknownQualifiedSuggestsModel(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	_, err := s.run(c, "ctrl:mymodle")
+	c.Assert(err, gc.ErrorMatches, `"ctrl:mymodle" is not the name of a model or controller, did you mean ctrl:admin/mymodel\?`)
+}
+
+func (s *SwitchSimpleSuite) TestSwitchUnknownNoSuggestions(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/default": {}},
+	}
+	_, err := s.run(c, "something-else")
+	c.Assert(err, gc.ErrorMatches, `"something-else" is not the name of a model or controller`)
+}
+
 func (s *SwitchSimpleSuite) TestSwitchUnknownCurrentControllerRefreshModelsFails(c *gc.C) {
 	s.store.CurrentControllerName = "ctrl"
 	s.addController(c, "ctrl")
@@ -306,12 +642,381 @@ func (s *SwitchSimpleSuite) TestSwitchUnknownCurrentControllerRefreshModelsFails
 	s.CheckCallNames(c, "RefreshModels")
 }
 
+func (s *SwitchSimpleSuite) TestSwitchRecordsPreviousController(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	_, err := s.run(c, "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.previousTarget, gc.Equals, "old:")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchRecordPreviousFails(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.writePreviousErr = errors.New("disk full")
+	ctx, err := s.run(c, "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "old (controller) -> new (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+	c.Assert(s.previousTarget, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchRecordsPreviousModel(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/mymodel":    {},
+			"admin/othermodel": {},
+		},
+		CurrentModel: "admin/mymodel",
+	}
+	_, err := s.run(c, "othermodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.previousTarget, gc.Equals, "ctrl:admin/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchNoChangeKeepsPrevious(c *gc.C) {
+	s.store.CurrentControllerName = "same"
+	s.addController(c, "same")
+	s.previousTarget = "other:"
+	_, err := s.run(c, "same")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.previousTarget, gc.Equals, "other:")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchPrevious(c *gc.C) {
+	s.store.CurrentControllerName = "new"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.previousTarget = "old:"
+	context, err := s.run(c, "-")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "new (controller) -> old (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "old")
+	c.Assert(s.previousTarget, gc.Equals, "new:")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchPreviousModel(c *gc.C) {
+	s.store.CurrentControllerName = "new"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Models["old"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	s.previousTarget = "old:admin/mymodel"
+	context, err := s.run(c, "-")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "new (controller) -> old:admin/mymodel\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "old")
+	c.Assert(s.store.Models["old"].CurrentModel, gc.Equals, "admin/mymodel")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchPreviousNone(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	_, err := s.run(c, "-")
+	c.Assert(err, gc.ErrorMatches, "no previous controller or model to switch to")
+}
+
+func (s *SwitchSimpleSuite) TestList(c *gc.C) {
+	s.store.CurrentControllerName = "b-controller"
+	s.addController(c, "c-controller")
+	s.addController(c, "a-controller")
+	s.addController(c, "b-controller")
+	s.store.Models["b-controller"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/zmodel":  {},
+			"admin/amodel":  {},
+			"bob/mymodel":   {},
+			"admin/default": {},
+		},
+		CurrentModel: "admin/default",
+	}
+	s.store.Models["a-controller"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/hidden": {}},
+	}
+	ctx, err := s.run(c, "--list")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, `
+  a-controller:
+* b-controller:
+  c-controller:
+  b-controller:admin/amodel
+* b-controller:admin/default
+  b-controller:admin/zmodel
+  b-controller:bob/mymodel
+`[1:])
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "b-controller")
+	c.Assert(s.previousTarget, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestListNoCurrentController(c *gc.C) {
+	s.addController(c, "b-controller")
+	s.addController(c, "a-controller")
+	ctx, err := s.run(c, "--list")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "  a-controller:\n  b-controller:\n")
+}
+
+func (s *SwitchSimpleSuite) TestListWithTarget(c *gc.C) {
+	_, err := s.run(c, "--list", "foo")
+	c.Assert(err, gc.ErrorMatches, "cannot specify a target with --list")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerPrefix(c *gc.C) {
+	s.addController(c, "aws-us-east-1-prod")
+	s.addController(c, "gce-prod")
+	context, err := s.run(c, "aws")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, " -> aws-us-east-1-prod (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "aws-us-east-1-prod")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerPrefixExplicit(c *gc.C) {
+	s.store.CurrentControllerName = "gce-prod"
+	s.addController(c, "aws-us-east-1-prod")
+	s.addController(c, "gce-prod")
+	context, err := s.run(c, "aws:")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "gce-prod (controller) -> aws-us-east-1-prod (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "aws-us-east-1-prod")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerPrefixAmbiguous(c *gc.C) {
+	s.addController(c, "aws-us-east-1-prod")
+	s.addController(c, "aws-us-west-2-prod")
+	_, err := s.run(c, "aws")
+	c.Assert(err, gc.ErrorMatches, `"aws" is ambiguous, could be any of: aws-us-east-1-prod:, aws-us-west-2-prod:`)
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerPrefixNoMatch(c *gc.C) {
+	s.addController(c, "aws-us-east-1-prod")
+	_, err := s.run(c, "gce:")
+	c.Assert(err, gc.ErrorMatches, "controller gce not found")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchControllerPrefixWithModel(c *gc.C) {
+	s.store.CurrentControllerName = "gce-prod"
+	s.addController(c, "aws-us-east-1-prod")
+	s.addController(c, "gce-prod")
+	s.store.Models["aws-us-east-1-prod"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	context, err := s.run(c, "aws:mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "gce-prod (controller) -> aws-us-east-1-prod:admin/mymodel\n")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchModelPrefix(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/production": {},
+			"admin/staging":    {},
+		},
+	}
+	context, err := s.run(c, "prod")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "ctrl (controller) -> ctrl:admin/production\n")
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "admin/production")
+	s.CheckCallNames(c, "RefreshModels")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchModelPrefixAmbiguous(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{
+			"admin/prod-a": {},
+			"admin/prod-b": {},
+		},
+	}
+	_, err := s.run(c, "prod")
+	c.Assert(err, gc.ErrorMatches, `"prod" is ambiguous, could be any of: ctrl:admin/prod-a, ctrl:admin/prod-b`)
+	c.Assert(s.store.Models["ctrl"].CurrentModel, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchModelPrefixNoMatch(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.store.Models["ctrl"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/production": {}},
+	}
+	_, err := s.run(c, "staging")
+	c.Assert(err, gc.ErrorMatches, `"staging" is not the name of a model or controller`)
+}
+
+func (s *SwitchSimpleSuite) TestSwitchExactMatchNotPrefix(c *gc.C) {
+	s.store.CurrentControllerName = "ctrl"
+	s.addController(c, "ctrl")
+	s.addController(c, "prod")
+	s.addController(c, "prod-2")
+	_, err := s.run(c, "prod")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "prod")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchFormatJSON(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Models["new"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	context, err := s.run(c, "--format", "json", "new:mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(context), gc.Equals,
+		`{"previous":"old","current":"new:admin/mymodel","type":"model","changed":true}`+"\n")
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchFormatYAML(c *gc.C) {
+	s.store.CurrentControllerName = "same"
+	s.addController(c, "same")
+	context, err := s.run(c, "--format", "yaml", "same")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(context), gc.Equals, `
+previous: same
+current: same
+type: controller
+changed: false
+`[1:])
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) addConfirmController(c *gc.C, name string) {
+	s.addController(c, name)
+	details := s.store.Controllers[name]
+	details.ConfirmSwitch = true
+	s.store.Controllers[name] = details
+}
+
+func (s *SwitchSimpleSuite) TestSwitchConfirmDeclined(c *gc.C) {
+	s.store.CurrentControllerName = "staging"
+	s.addController(c, "staging")
+	s.addConfirmController(c, "production")
+	context, err := s.runWithStdin(c, "n\n", "production")
+	c.Assert(err, gc.ErrorMatches, "switching controller: aborted")
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, `
+Controller "production" is marked as requiring confirmation before switching to it.
+
+Really switch to production? (y/N): `[1:])
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "staging")
+	c.Assert(s.previousTarget, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchConfirmDeclinedModel(c *gc.C) {
+	s.store.CurrentControllerName = "staging"
+	s.addController(c, "staging")
+	s.addConfirmController(c, "production")
+	s.store.Models["production"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	_, err := s.runWithStdin(c, "", "production:mymodel")
+	c.Assert(err, gc.ErrorMatches, "switching controller: aborted")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "staging")
+	c.Assert(s.store.Models["production"].CurrentModel, gc.Equals, "")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchConfirmAccepted(c *gc.C) {
+	s.store.CurrentControllerName = "staging"
+	s.addController(c, "staging")
+	s.addConfirmController(c, "production")
+	_, err := s.runWithStdin(c, "y\n", "production")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "production")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchConfirmAssumeYes(c *gc.C) {
+	s.store.CurrentControllerName = "staging"
+	s.addController(c, "staging")
+	s.addConfirmController(c, "production")
+	context, err := s.run(c, "--yes", "production")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(context), gc.Equals, "staging (controller) -> production (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "production")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchConfirmWithinController(c *gc.C) {
+	s.store.CurrentControllerName = "production"
+	s.addConfirmController(c, "production")
+	s.store.Models["production"] = &jujuclient.ControllerModels{
+		Models: map[string]jujuclient.ModelDetails{"admin/mymodel": {}},
+	}
+	_, err := s.runWithStdin(c, "", "mymodel")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.store.Models["production"].CurrentModel, gc.Equals, "admin/mymodel")
+}
+
 func (s *SwitchSimpleSuite) TestSettingWhenEnvVarSet(c *gc.C) {
 	os.Setenv("JUJU_MODEL", "using-model")
 	_, err := s.run(c, "erewhemos-2")
 	c.Assert(err, gc.ErrorMatches, `cannot switch when JUJU_MODEL is overriding the model \(set to "using-model"\)`)
 }
 
+func (s *SwitchSimpleSuite) TestSwitchCheck(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.store.Controllers["new"] = jujuclient.ControllerDetails{
+		APIEndpoints: []string{"10.0.0.1:17070", "10.0.0.2:17070"},
+	}
+	ctx, err := s.run(c, "--check", "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "old (controller) -> new (controller)\n")
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+	s.CheckCalls(c, []testing.StubCall{
+		{"ProbeAPI", []interface{}{[]string{"10.0.0.1:17070", "10.0.0.2:17070"}}},
+	})
+}
+
+func (s *SwitchSimpleSuite) TestSwitchCheckUnreachable(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	s.probeErr = errors.New("connection refused")
+	ctx, err := s.run(c, "--check", "new")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+switched to new, but its API is currently unreachable
+old (controller) -> new (controller)
+`[1:])
+	c.Assert(s.store.CurrentControllerName, gc.Equals, "new")
+}
+
+func (s *SwitchSimpleSuite) TestSwitchNoCheck(c *gc.C) {
+	s.store.CurrentControllerName = "old"
+	s.addController(c, "old")
+	s.addController(c, "new")
+	_, err := s.run(c, "new")
+	c.Assert(err, jc.ErrorIsNil)
+	s.CheckNoCalls(c)
+}
+
+func (s *SwitchSimpleSuite) TestProbeAPIEndpoints(c *gc.C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, jc.ErrorIsNil)
+	reachable := listener.Addr().String()
+	unreachable, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, jc.ErrorIsNil)
+	unreachableAddr := unreachable.Addr().String()
+	unreachable.Close()
+
+	err = probeAPIEndpoints([]string{unreachableAddr, reachable})
+	c.Assert(err, jc.ErrorIsNil)
+	listener.Close()
+	err = probeAPIEndpoints([]string{unreachableAddr})
+	c.Assert(err, gc.NotNil)
+	err = probeAPIEndpoints(nil)
+	c.Assert(err, gc.ErrorMatches, "no API endpoints")
+}
+
 func (s *SwitchSimpleSuite) TestTooManyParams(c *gc.C) {
 	_, err := s.run(c, "foo", "bar")
 	c.Assert(err, gc.ErrorMatches, `unrecognized args: ."bar".`)
@@ -239,7 +239,7 @@ func (s *ValidateImageMetadataSuite) TestEc2LocalMetadataNoMatch(c *gc.C) {
 		"-p", "ec2", "-s", "precise", "-r", "region",
 		"-u", "https://ec2.region.amazonaws.com", "-d", s.metadataDir,
 	)
-	c.Check(err, gc.ErrorMatches, `unknown region "region"`)
+	c.Check(err, gc.ErrorMatches, `unknown region "region", expected one of: .*`)
 }
 
 func (s *ValidateImageMetadataSuite) TestOpenstackLocalMetadataWithManualParams(c *gc.C) {
@@ -161,7 +161,7 @@ func (s *ValidateToolsMetadataSuite) TestEc2LocalMetadataNoMatch(c *gc.C) {
 		"-p", "ec2", "-s", "precise", "-r", "region",
 		"-u", "https://ec2.region.amazonaws.com", "-d", s.metadataDir,
 	)
-	c.Assert(err, gc.ErrorMatches, `unknown region "region"`)
+	c.Assert(err, gc.ErrorMatches, `unknown region "region", expected one of: .*`)
 }
 
 func (s *ValidateToolsMetadataSuite) TestOpenstackLocalMetadataWithManualParams(c *gc.C) {
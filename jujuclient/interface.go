@@ -67,6 +67,11 @@ type ControllerDetails struct {
 	// which a user has access. It is cached here so under normal
 	// usage list-controllers does not need to hit the server.
 	MachineCount *int `yaml:"machine-count,omitempty"`
+
+	// ConfirmSwitch indicates that "juju switch" should ask the
+	// user for confirmation before making this the current
+	// controller.
+	ConfirmSwitch bool `yaml:"confirm-switch,omitempty"`
 }
 
 // ModelDetails holds details of a model.
@@ -0,0 +1,92 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"sync"
+
+	"gopkg.in/amz.v3/ec2"
+)
+
+// APICallStats records the requests made for one type of EC2 API call,
+// to help diagnose problems caused by EC2's API rate limits.
+type APICallStats struct {
+	// Requests is the total number of requests made.
+	Requests int
+
+	// Retries is the number of requests that were retries
+	// of earlier, failed requests.
+	Retries int
+
+	// Throttled is the number of requests that EC2 rejected
+	// with RequestLimitExceeded.
+	Throttled int
+}
+
+// apiStats records APICallStats for each type of API call.
+// The zero value is ready to use.
+type apiStats struct {
+	mu    sync.Mutex
+	calls map[string]APICallStats
+}
+
+// record records the outcome of a request for the named API call.
+// The retry argument reports whether the request is a retry.
+func (s *apiStats) record(call string, retry bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[string]APICallStats)
+	}
+	stats := s.calls[call]
+	stats.Requests++
+	if retry {
+		stats.Retries++
+	}
+	if ec2ErrCode(err) == "RequestLimitExceeded" {
+		stats.Throttled++
+	}
+	s.calls[call] = stats
+}
+
+// snapshot returns a copy of the recorded stats, keyed by API call.
+func (s *apiStats) snapshot() map[string]APICallStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]APICallStats, len(s.calls))
+	for call, stats := range s.calls {
+		result[call] = stats
+	}
+	return result
+}
+
+// APIStats returns the number of requests, retries and throttled
+// requests made by the environ for each type of EC2 API call that
+// is retried on transient errors.
+func (e *environ) APIStats() map[string]APICallStats {
+	return e.apiStats.snapshot()
+}
+
+// statsSecurityGroupCleaner is a SecurityGroupCleaner that records
+// the DeleteSecurityGroup requests it makes. A new one should be
+// used for each group deleted, so that retries are counted.
+type statsSecurityGroupCleaner struct {
+	client   *ec2.EC2
+	stats    *apiStats
+	requests int
+}
+
+// DeleteSecurityGroup is part of the SecurityGroupCleaner interface.
+func (c *statsSecurityGroupCleaner) DeleteSecurityGroup(group ec2.SecurityGroup) (*ec2.SimpleResp, error) {
+	resp, err := c.client.DeleteSecurityGroup(group)
+	c.stats.record("DeleteSecurityGroup", c.requests > 0, err)
+	c.requests++
+	return resp, err
+}
+
+// securityGroupCleaner returns a SecurityGroupCleaner that deletes
+// security groups with the environ's client, recording its requests.
+func (e *environ) securityGroupCleaner() SecurityGroupCleaner {
+	return &statsSecurityGroupCleaner{client: e.ec2, stats: &e.apiStats}
+}
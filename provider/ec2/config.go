@@ -5,6 +5,9 @@ package ec2
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/juju/schema"
 	"gopkg.in/juju/environschema.v1"
@@ -26,6 +29,29 @@ var configSchema = environschema.Fields{
 		Group:       environschema.AccountGroup,
 		Immutable:   true,
 	},
+	"image-id": {
+		Description: "Use a specific AMI for all instances (optional). When not specified, Juju chooses an image from the image metadata for the instance's series.",
+		Example:     "ami-a1b2c3d4",
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	"iam-instance-profile": {
+		Description: "Assign an IAM instance profile to all instances (optional), allowing them to assume its role without static credentials. Specified as either the name or the ARN of the instance profile.",
+		Example:     "juju-instance-profile",
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	"key-name": {
+		Description: "Launch instances with the named EC2 key pair (optional), in addition to the SSH keys Juju installs. The key pair must exist in the model's region.",
+		Example:     "my-key-pair",
+		Type:        environschema.Tstring,
+		Group:       environschema.EnvironGroup,
+	},
+	"enable-monitoring": {
+		Description: "Enable detailed (1-minute) CloudWatch monitoring for new instances. Detailed monitoring incurs additional charges, so it is disabled by default.",
+		Type:        environschema.Tbool,
+		Group:       environschema.EnvironGroup,
+	},
 }
 
 var configFields = func() schema.Fields {
@@ -37,8 +63,12 @@ var configFields = func() schema.Fields {
 }()
 
 var configDefaults = schema.Defaults{
-	"vpc-id":       "",
-	"vpc-id-force": false,
+	"vpc-id":               "",
+	"vpc-id-force":         false,
+	"image-id":             "",
+	"iam-instance-profile": "",
+	"key-name":             "",
+	"enable-monitoring":    false,
 }
 
 type environConfig struct {
@@ -54,6 +84,71 @@ func (c *environConfig) forceVPCID() bool {
 	return c.attrs["vpc-id-force"].(bool)
 }
 
+func (c *environConfig) imageID() string {
+	return c.attrs["image-id"].(string)
+}
+
+func (c *environConfig) iamInstanceProfile() string {
+	return c.attrs["iam-instance-profile"].(string)
+}
+
+func (c *environConfig) keyName() string {
+	return c.attrs["key-name"].(string)
+}
+
+func (c *environConfig) enableMonitoring() bool {
+	return c.attrs["enable-monitoring"].(bool)
+}
+
+var (
+	iamInstanceProfileNameRegexp = regexp.MustCompile(`^[\w+=,.@-]{1,128}$`)
+	iamInstanceProfileARNRegexp  = regexp.MustCompile(`^arn:aws[\w-]*:iam::\d{12}:instance-profile/(?:[\w+=,.@-]+/)*([\w+=,.@-]{1,128})$`)
+)
+
+// iamInstanceProfileName returns the name of the IAM instance profile
+// identified by the given name or ARN, or an error if it is neither.
+func iamInstanceProfileName(profile string) (string, error) {
+	if strings.HasPrefix(profile, "arn:") {
+		if m := iamInstanceProfileARNRegexp.FindStringSubmatch(profile); m != nil {
+			return m[1], nil
+		}
+		return "", fmt.Errorf("%q is not a valid IAM instance profile ARN", profile)
+	}
+	if !iamInstanceProfileNameRegexp.MatchString(profile) {
+		return "", fmt.Errorf("%q is not a valid IAM instance profile name or ARN", profile)
+	}
+	return profile, nil
+}
+
+const (
+	// maxTagKeyLength and maxTagValueLength are the maximum
+	// lengths of the key and value of an EC2 resource tag.
+	maxTagKeyLength   = 127
+	maxTagValueLength = 255
+
+	// awsTagPrefix is the tag key prefix reserved for use by AWS.
+	awsTagPrefix = "aws:"
+)
+
+// validateResourceTags returns an error if any of the given
+// resource tags cannot be applied to EC2 resources, or would
+// override a tag that Juju sets itself.
+func validateResourceTags(resourceTags map[string]string) error {
+	for k, v := range resourceTags {
+		switch {
+		case k == tagName:
+			return fmt.Errorf("tag %q is reserved", k)
+		case strings.HasPrefix(k, awsTagPrefix):
+			return fmt.Errorf("tag %q uses reserved prefix %q", k, awsTagPrefix)
+		case utf8.RuneCountInString(k) > maxTagKeyLength:
+			return fmt.Errorf("tag key %q longer than %d characters", k, maxTagKeyLength)
+		case utf8.RuneCountInString(v) > maxTagValueLength:
+			return fmt.Errorf("tag %q value longer than %d characters", k, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
 func (p environProvider) newConfig(cfg *config.Config) (*environConfig, error) {
 	valid, err := p.Validate(cfg, nil)
 	if err != nil {
@@ -100,6 +195,22 @@ func validateConfig(cfg, old *config.Config) (*environConfig, error) {
 		return nil, fmt.Errorf("cannot use vpc-id-force without specifying vpc-id as well")
 	}
 
+	if imageID := ecfg.imageID(); imageID != "" && !strings.HasPrefix(imageID, "ami-") {
+		return nil, fmt.Errorf("image-id: %q is not a valid EC2 AMI ID", imageID)
+	}
+
+	if profile := ecfg.iamInstanceProfile(); profile != "" {
+		if _, err := iamInstanceProfileName(profile); err != nil {
+			return nil, fmt.Errorf("iam-instance-profile: %v", err)
+		}
+	}
+
+	if resourceTags, ok := cfg.ResourceTags(); ok {
+		if err := validateResourceTags(resourceTags); err != nil {
+			return nil, fmt.Errorf("resource-tags: %v", err)
+		}
+	}
+
 	if old != nil {
 		attrs := old.UnknownAttrs()
 
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
@@ -242,6 +243,93 @@ var configTests = []configTest{
 		change:     attrs{},
 		vpcID:      "vpc-foo",
 		forceVPCID: true,
+	}, {
+		config: attrs{
+			"image-id": "ami-a1b2c3d4",
+		},
+		expect: attrs{
+			"image-id": "ami-a1b2c3d4",
+		},
+	}, {
+		config: attrs{
+			"image-id": "a1b2c3d4",
+		},
+		err: `.*image-id: "a1b2c3d4" is not a valid EC2 AMI ID`,
+	}, {
+		config: attrs{
+			"iam-instance-profile": "juju-instance-profile",
+		},
+		expect: attrs{
+			"iam-instance-profile": "juju-instance-profile",
+		},
+	}, {
+		config: attrs{
+			"iam-instance-profile": "arn:aws:iam::123456789012:instance-profile/path/juju-instance-profile",
+		},
+		expect: attrs{
+			"iam-instance-profile": "arn:aws:iam::123456789012:instance-profile/path/juju-instance-profile",
+		},
+	}, {
+		config: attrs{
+			"iam-instance-profile": "arn:aws:iam::123456789012:role/juju-role",
+		},
+		err: `.*iam-instance-profile: "arn:aws:iam::123456789012:role/juju-role" is not a valid IAM instance profile ARN`,
+	}, {
+		config: attrs{
+			"iam-instance-profile": "instance-profile/juju-instance-profile",
+		},
+		err: `.*iam-instance-profile: "instance-profile/juju-instance-profile" is not a valid IAM instance profile name or ARN`,
+	}, {
+		config: attrs{
+			"key-name": "break-glass",
+		},
+		expect: attrs{
+			"key-name": "break-glass",
+		},
+	}, {
+		config: attrs{
+			"enable-monitoring": true,
+		},
+		expect: attrs{
+			"enable-monitoring": true,
+		},
+	}, {
+		config: attrs{
+			"resource-tags": "cost-center=1234 team=infra",
+		},
+	}, {
+		config: attrs{
+			"resource-tags": "Name=mine",
+		},
+		err: `.*resource-tags: tag "Name" is reserved`,
+	}, {
+		config: attrs{
+			"resource-tags": "aws:owner=me",
+		},
+		err: `.*resource-tags: tag "aws:owner" uses reserved prefix "aws:"`,
+	}, {
+		config: attrs{
+			"resource-tags": strings.Repeat("k", 128) + "=v",
+		},
+		err: `.*resource-tags: tag key "k+" longer than 127 characters`,
+	}, {
+		config: attrs{
+			"resource-tags": "team=" + strings.Repeat("v", 256),
+		},
+		err: `.*resource-tags: tag "team" value longer than 255 characters`,
+	}, {
+		// The limits are in characters, not bytes.
+		config: attrs{
+			"resource-tags": strings.Repeat("é", 127) + "=" + strings.Repeat("ü", 255),
+		},
+	}, {
+		config: attrs{},
+		change: attrs{
+			"image-id": "ami-a1b2c3d4",
+		},
+		expect: attrs{
+			"image-id": "ami-a1b2c3d4",
+		},
 	}, {
 		config:       attrs{},
 		firewallMode: config.FwInstance,
@@ -359,7 +359,7 @@ func (v *ebsVolumeSource) createVolume(p storage.VolumeParams, instances instanc
 		resourceTags[k] = v
 	}
 	resourceTags[tagName] = resourceName(p.Tag, v.envName)
-	if err := tagResources(v.env.ec2, resourceTags, volumeId); err != nil {
+	if err := tagResources(v.env.ec2, &v.env.apiStats, resourceTags, volumeId); err != nil {
 		return nil, nil, errors.Annotate(err, "tagging volume")
 	}
 
@@ -445,17 +445,17 @@ func (v *ebsVolumeSource) DescribeVolumes(volIds []string) ([]storage.DescribeVo
 
 // DestroyVolumes is specified on the storage.VolumeSource interface.
 func (v *ebsVolumeSource) DestroyVolumes(volIds []string) ([]error, error) {
-	return destroyVolumes(v.env.ec2, volIds), nil
+	return destroyVolumes(v.env.ec2, &v.env.apiStats, volIds), nil
 }
 
-func destroyVolumes(client *ec2.EC2, volIds []string) []error {
+func destroyVolumes(client *ec2.EC2, stats *apiStats, volIds []string) []error {
 	var wg sync.WaitGroup
 	wg.Add(len(volIds))
 	results := make([]error, len(volIds))
 	for i, volumeId := range volIds {
 		go func(i int, volumeId string) {
 			defer wg.Done()
-			results[i] = destroyVolume(client, volumeId)
+			results[i] = destroyVolume(client, stats, volumeId)
 		}(i, volumeId)
 	}
 	wg.Wait()
@@ -467,7 +467,7 @@ var destroyVolumeAttempt = utils.AttemptStrategy{
 	Delay: 5 * time.Second,
 }
 
-func destroyVolume(client *ec2.EC2, volumeId string) (err error) {
+func destroyVolume(client *ec2.EC2, stats *apiStats, volumeId string) (err error) {
 	defer func() {
 		if err != nil {
 			if ec2ErrCode(err) == volumeNotFound || errors.IsNotFound(err) {
@@ -485,7 +485,7 @@ func destroyVolume(client *ec2.EC2, volumeId string) (err error) {
 	// Volumes must not be in-use when destroying. A volume may
 	// still be in-use when the instance it is attached to is
 	// in the process of being terminated.
-	volume, err := waitVolume(client, volumeId, destroyVolumeAttempt, func(volume *ec2.Volume) (bool, error) {
+	volume, err := waitVolume(client, stats, volumeId, destroyVolumeAttempt, func(volume *ec2.Volume) (bool, error) {
 		if volume.Status != volumeStatusInUse {
 			// Volume is not in use, it should be OK to destroy now.
 			return true, nil
@@ -727,7 +727,7 @@ func (v *ebsVolumeSource) waitVolumeCreated(volumeId string) (*ec2.Volume, error
 		Delay: 200 * time.Millisecond,
 	}
 	var lastStatus string
-	volume, err := waitVolume(v.env.ec2, volumeId, attempt, func(volume *ec2.Volume) (bool, error) {
+	volume, err := waitVolume(v.env.ec2, &v.env.apiStats, volumeId, attempt, func(volume *ec2.Volume) (bool, error) {
 		lastStatus = volume.Status
 		return volume.Status != volumeStatusCreating, nil
 	})
@@ -746,12 +746,14 @@ var errWaitVolumeTimeout = errors.New("timed out")
 
 func waitVolume(
 	client *ec2.EC2,
+	stats *apiStats,
 	volumeId string,
 	attempt utils.AttemptStrategy,
 	pred func(v *ec2.Volume) (bool, error),
 ) (*ec2.Volume, error) {
-	for a := attempt.Start(); a.Next(); {
+	for i, a := 0, attempt.Start(); a.Next(); i++ {
 		volume, err := describeVolume(client, volumeId)
+		stats.record("DescribeVolumes", i > 0, err)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -46,6 +46,10 @@ const (
 	tagName = "Name"
 )
 
+// maxUserDataSize is the maximum size, in bytes, of the (compressed)
+// user data that EC2 will accept when running an instance.
+var maxUserDataSize = 16 * 1024
+
 var (
 	// Use shortAttempt to poll for short-term events or for retrying API calls.
 	// TODO(katco): 2016-08-09: lp:1611427
@@ -74,6 +78,9 @@ type environ struct {
 	defaultVPCMutex   sync.Mutex
 	defaultVPCChecked bool
 	defaultVPC        *ec2.VPC
+
+	// apiStats records the requests made by retrying API calls.
+	apiStats apiStats
 }
 
 func (e *environ) Config() *config.Config {
@@ -345,7 +352,7 @@ func (e *environ) MetadataLookupParams(region string) (*simplestreams.MetadataLo
 		// are not expected to know how to map regions to endpoints.
 		ec2Region, ok := aws.Regions[region]
 		if !ok {
-			return nil, errors.Errorf("unknown region %q", region)
+			return nil, unknownRegionError(region)
 		}
 		endpoint = ec2Region.EC2Endpoint
 	}
@@ -453,9 +460,14 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		return nil, errors.Trace(err)
 	}
 
+	imageMetadata := args.ImageMetadata
+	if imageID := e.ecfg().imageID(); imageID != "" {
+		logger.Debugf("using image %q from model config", imageID)
+		imageMetadata = explicitImageMetadata(imageID, arches)
+	}
 	spec, err := findInstanceSpec(
 		args.InstanceConfig.Controller != nil,
-		args.ImageMetadata,
+		imageMetadata,
 		instanceTypes,
 		&instances.InstanceConstraint{
 			Region:      e.cloud.Region,
@@ -490,6 +502,12 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		return nil, errors.Annotate(err, "cannot make user data")
 	}
 	logger.Debugf("ec2 user data; %d bytes", len(userData))
+	if len(userData) > maxUserDataSize {
+		return nil, errors.Errorf(
+			"user data is %d bytes, exceeding the EC2 limit of %d bytes",
+			len(userData), maxUserDataSize,
+		)
+	}
 	var apiPort int
 	if args.InstanceConfig.Controller != nil {
 		apiPort = args.InstanceConfig.Controller.Config.APIPort()
@@ -532,6 +550,12 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		SecurityGroups:      groups,
 		BlockDeviceMappings: blockDeviceMappings,
 		ImageId:             spec.Image.Id,
+		KeyName:             e.ecfg().keyName(),
+		Monitoring:          e.ecfg().enableMonitoring(),
+	}
+	if profile := e.ecfg().iamInstanceProfile(); profile != "" {
+		// The profile was validated with the model config.
+		commonRunArgs.IamInstanceProfile, _ = iamInstanceProfileName(profile)
 	}
 
 	haveVPCID := isVPCIDSet(e.ecfg().vpcID())
@@ -588,7 +612,7 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		}
 
 		callback(status.Allocating, fmt.Sprintf("Trying to start instance in availability zone %q", zone), nil)
-		instResp, err = runInstances(e.ec2, runArgs, callback)
+		instResp, err = e.runInstances(runArgs, callback)
 		if err == nil || !isZoneOrSubnetConstrainedError(err) {
 			break
 		}
@@ -621,7 +645,7 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 		names.NewMachineTag(args.InstanceConfig.MachineId), e.Config().Name(),
 	)
 	args.InstanceConfig.Tags[tagName] = instanceName
-	if err := tagResources(e.ec2, args.InstanceConfig.Tags, string(inst.Id())); err != nil {
+	if err := tagResources(e.ec2, &e.apiStats, args.InstanceConfig.Tags, string(inst.Id())); err != nil {
 		return nil, errors.Annotate(err, "tagging instance")
 	}
 
@@ -634,7 +658,7 @@ func (e *environ) StartInstance(args environs.StartInstanceParams) (_ *environs.
 			cfg,
 		)
 		tags[tagName] = instanceName + "-root"
-		if err := tagRootDisk(e.ec2, tags, inst.Instance); err != nil {
+		if err := tagRootDisk(e.ec2, &e.apiStats, tags, inst.Instance); err != nil {
 			return nil, errors.Annotate(err, "tagging root disk")
 		}
 	}
@@ -720,7 +744,7 @@ func volumeAttachmentsZone(ec2 *ec2.EC2, attachments []storage.VolumeAttachmentP
 // tagResources calls ec2.CreateTags, tagging each of the specified resources
 // with the given tags. tagResources will retry for a short period of time
 // if it receives a *.NotFound error response from EC2.
-func tagResources(e *ec2.EC2, tags map[string]string, resourceIds ...string) error {
+func tagResources(e *ec2.EC2, stats *apiStats, tags map[string]string, resourceIds ...string) error {
 	if len(tags) == 0 {
 		return nil
 	}
@@ -729,8 +753,9 @@ func tagResources(e *ec2.EC2, tags map[string]string, resourceIds ...string) err
 		ec2Tags = append(ec2Tags, ec2.Tag{k, v})
 	}
 	var err error
-	for a := shortAttempt.Start(); a.Next(); {
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
 		_, err = e.CreateTags(resourceIds, ec2Tags)
+		stats.record("CreateTags", i > 0, err)
 		if err == nil || !strings.HasSuffix(ec2ErrCode(err), ".NotFound") {
 			return err
 		}
@@ -738,7 +763,7 @@ func tagResources(e *ec2.EC2, tags map[string]string, resourceIds ...string) err
 	return err
 }
 
-func tagRootDisk(e *ec2.EC2, tags map[string]string, inst *ec2.Instance) error {
+func tagRootDisk(e *ec2.EC2, stats *apiStats, tags map[string]string, inst *ec2.Instance) error {
 	if len(tags) == 0 {
 		return nil
 	}
@@ -759,8 +784,9 @@ func tagRootDisk(e *ec2.EC2, tags map[string]string, inst *ec2.Instance) error {
 		Total: 5 * time.Minute,
 		Delay: 5 * time.Second,
 	}
-	for a := waitRootDiskAttempt.Start(); volumeId == "" && a.Next(); {
+	for i, a := 0, waitRootDiskAttempt.Start(); volumeId == "" && a.Next(); i++ {
 		resp, err := e.Instances([]string{inst.InstanceId}, nil)
+		stats.record("DescribeInstances", i > 0, err)
 		if err = errors.Annotate(err, "cannot fetch instance information"); err != nil {
 			logger.Warningf("%v", err)
 			if a.HasNext() == false {
@@ -777,27 +803,30 @@ func tagRootDisk(e *ec2.EC2, tags map[string]string, inst *ec2.Instance) error {
 	if volumeId == "" {
 		return errors.New("timed out waiting for EBS volume to be associated")
 	}
-	return tagResources(e, tags, volumeId)
+	return tagResources(e, stats, tags, volumeId)
 }
 
-var runInstances = _runInstances
-
 // runInstances calls ec2.RunInstances for a fixed number of attempts until
-// RunInstances returns an error code that does not indicate an error that
-// may be caused by eventual consistency.
-func _runInstances(e *ec2.EC2, ri *ec2.RunInstances, c environs.StatusCallbackFunc) (resp *ec2.RunInstancesResp, err error) {
-	try := 1
-	for a := shortAttempt.Start(); a.Next(); {
-		c(status.Allocating, fmt.Sprintf("Start instance attempt %d", try), nil)
-		resp, err = e.RunInstances(ri)
-		if err == nil || !isNotFoundError(err) {
+// RunInstances returns an error code that does not indicate a transient
+// error. Errors indicating that the requested zone or subnet is constrained
+// are returned immediately, so that the caller may try another zone.
+func (e *environ) runInstances(ri *ec2.RunInstances, c environs.StatusCallbackFunc) (resp *ec2.RunInstancesResp, err error) {
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
+		c(status.Allocating, fmt.Sprintf("Start instance attempt %d", i+1), nil)
+		resp, err = runInstances(e.ec2, ri)
+		e.apiStats.record("RunInstances", i > 0, err)
+		if err == nil || !isTransientError(err) || isZoneOrSubnetConstrainedError(err) {
 			break
 		}
-		try++
+		logger.Debugf("retrying start instance after transient error: %v", err)
 	}
 	return resp, err
 }
 
+var runInstances = func(ec2inst *ec2.EC2, ri *ec2.RunInstances) (*ec2.RunInstancesResp, error) {
+	return ec2inst.RunInstances(ri)
+}
+
 func (e *environ) StopInstances(ids ...instance.Id) error {
 	return errors.Trace(e.terminateInstances(ids))
 }
@@ -834,6 +863,41 @@ func isNotFoundError(err error) bool {
 
 // Instances is part of the environs.Environ interface.
 func (e *environ) Instances(ids []instance.Id) ([]instance.Instance, error) {
+	return e.instancesInState(ids, aliveInstanceStates)
+}
+
+// Instance returns the pending or running instance with the given id.
+// If there is no such instance, environs.ErrNoInstances is returned.
+func (e *environ) Instance(id instance.Id) (instance.Instance, error) {
+	insts, err := e.Instances([]instance.Id{id})
+	if err != nil {
+		return nil, err
+	}
+	return insts[0], nil
+}
+
+// instanceStates holds the names of the lifecycle states
+// that EC2 instances may be in.
+var instanceStates = set.NewStrings(
+	"pending", "running", "shutting-down", "terminated", "stopping", "stopped",
+)
+
+// InstancesInState is like Instances, but returns the instances
+// in any of the given lifecycle states rather than only those that
+// are pending or running.
+func (e *environ) InstancesInState(ids []instance.Id, states ...string) ([]instance.Instance, error) {
+	if len(states) == 0 {
+		return nil, errors.New("no instance states specified")
+	}
+	for _, state := range states {
+		if !instanceStates.Contains(state) {
+			return nil, errors.NotValidf("instance state %q", state)
+		}
+	}
+	return e.instancesInState(ids, states)
+}
+
+func (e *environ) instancesInState(ids []instance.Id, states []string) ([]instance.Instance, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
@@ -842,7 +906,7 @@ func (e *environ) Instances(ids []instance.Id) ([]instance.Instance, error) {
 	// Each request will attempt to add more instances to the requested
 	// set.
 	var err error
-	for a := shortAttempt.Start(); a.Next(); {
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
 		var need []string
 		for i, inst := range insts {
 			if inst == nil {
@@ -850,11 +914,12 @@ func (e *environ) Instances(ids []instance.Id) ([]instance.Instance, error) {
 			}
 		}
 		filter := ec2.NewFilter()
-		filter.Add("instance-state-name", aliveInstanceStates...)
+		filter.Add("instance-state-name", states...)
 		filter.Add("instance-id", need...)
 		e.addModelFilter(filter)
 		err = e.gatherInstances(ids, insts, filter)
-		if err == nil || err != environs.ErrPartialInstances {
+		e.apiStats.record("DescribeInstances", i > 0, err)
+		if err == nil || (err != environs.ErrPartialInstances && !isTransientError(err)) {
 			break
 		}
 	}
@@ -872,6 +937,77 @@ func (e *environ) Instances(ids []instance.Id) ([]instance.Instance, error) {
 	return insts, nil
 }
 
+// WaitForInstances waits until each of the instances with the given
+// ids is running, and returns them in the same order as the ids. An
+// error naming the instances is returned if any of them is shutting
+// down, terminated or stopped, or if they are not all running within
+// the given timeout.
+func (e *environ) WaitForInstances(ids []instance.Id, timeout time.Duration) ([]instance.Instance, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = string(id)
+	}
+	attempt := utils.AttemptStrategy{
+		Total: timeout,
+		Delay: shortAttempt.Delay,
+	}
+	var pending []string
+	var err error
+	for i, a := 0, attempt.Start(); a.Next(); i++ {
+		filter := ec2.NewFilter()
+		filter.Add("instance-id", strs...)
+		e.addModelFilter(filter)
+		var resp *ec2.InstancesResp
+		resp, err = e.ec2.Instances(nil, filter)
+		e.apiStats.record("DescribeInstances", i > 0, err)
+		if err != nil {
+			if isTransientError(err) {
+				continue
+			}
+			return nil, errors.Annotate(err, "listing instances")
+		}
+		found := make(map[string]*ec2.Instance)
+		for i := range resp.Reservations {
+			r := &resp.Reservations[i]
+			for j := range r.Instances {
+				found[r.Instances[j].InstanceId] = &r.Instances[j]
+			}
+		}
+		insts := make([]instance.Instance, len(ids))
+		var notAlive []string
+		pending = nil
+		for i, id := range strs {
+			inst, ok := found[id]
+			switch {
+			case !ok, inst.State.Name == "pending":
+				// Instances may not be visible immediately
+				// after they are started.
+				pending = append(pending, id)
+			case inst.State.Name == "running":
+				insts[i] = &ec2Instance{e: e, Instance: inst}
+			default:
+				notAlive = append(notAlive, fmt.Sprintf("%s (%s)", id, inst.State.Name))
+			}
+		}
+		if len(notAlive) > 0 {
+			return nil, errors.Errorf("instances not running: %s", strings.Join(notAlive, ", "))
+		}
+		if len(pending) == 0 {
+			return insts, nil
+		}
+	}
+	if pending == nil && err != nil {
+		return nil, errors.Annotate(err, "listing instances")
+	}
+	return nil, errors.Errorf(
+		"timed out waiting for instances to be running: %s",
+		strings.Join(pending, ", "),
+	)
+}
+
 // gatherInstances tries to get information on each instance
 // id whose corresponding insts slot is nil.
 //
@@ -886,42 +1022,58 @@ func (e *environ) gatherInstances(
 	if err != nil {
 		return err
 	}
-	n := 0
-	// For each requested id, add it to the returned instances
-	// if we find it in the response.
+	if !e.fillInstances(ids, insts, resp.Reservations) {
+		return environs.ErrPartialInstances
+	}
+	return nil
+}
+
+// fillInstances sets each nil slot of insts to the instance in the
+// given reservations with the corresponding id, and reports whether
+// all slots have been filled. EC2 may transiently report the same
+// instance in more than one reservation; completeness is decided by
+// the slots filled, so duplicates cannot make up for missing instances.
+func (e *environ) fillInstances(
+	ids []instance.Id,
+	insts []instance.Instance,
+	reservations []ec2.Reservation,
+) bool {
+	found := make(map[string]*ec2.Instance)
+	for i := range reservations {
+		r := &reservations[i]
+		for j := range r.Instances {
+			if _, ok := found[r.Instances[j].InstanceId]; !ok {
+				found[r.Instances[j].InstanceId] = &r.Instances[j]
+			}
+		}
+	}
+	complete := true
 	for i, id := range ids {
 		if insts[i] != nil {
-			n++
 			continue
 		}
-		for j := range resp.Reservations {
-			r := &resp.Reservations[j]
-			for k := range r.Instances {
-				if r.Instances[k].InstanceId != string(id) {
-					continue
-				}
-				inst := r.Instances[k]
-				// TODO(wallyworld): lookup the details to fill in the instance type data
-				insts[i] = &ec2Instance{e: e, Instance: &inst}
-				n++
-			}
+		inst, ok := found[string(id)]
+		if !ok {
+			complete = false
+			continue
 		}
+		// TODO(wallyworld): lookup the details to fill in the instance type data
+		instCopy := *inst
+		insts[i] = &ec2Instance{e: e, Instance: &instCopy}
 	}
-	if n < len(ids) {
-		return environs.ErrPartialInstances
-	}
-	return nil
+	return complete
 }
 
 // NetworkInterfaces implements NetworkingEnviron.NetworkInterfaces.
 func (e *environ) NetworkInterfaces(instId instance.Id) ([]network.InterfaceInfo, error) {
 	var err error
 	var networkInterfacesResp *ec2.NetworkInterfacesResp
-	for a := shortAttempt.Start(); a.Next(); {
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
 		logger.Tracef("retrieving NICs for instance %q", instId)
 		filter := ec2.NewFilter()
 		filter.Add("attachment.instance-id", string(instId))
 		networkInterfacesResp, err = e.ec2.NetworkInterfaces(nil, filter)
+		e.apiStats.record("DescribeNetworkInterfaces", i > 0, err)
 		logger.Tracef("instance %q NICs: %#v (err: %v)", instId, networkInterfacesResp, err)
 		if err != nil {
 			logger.Errorf("failed to get instance %q interfaces: %v (retrying)", instId, err)
@@ -1113,7 +1265,7 @@ func (e *environ) AdoptResources(controllerUUID string, fromVersion version.Numb
 	resourceIds = append(resourceIds, groupIds...)
 
 	tags := map[string]string{tags.JujuController: controllerUUID}
-	return errors.Annotate(tagResources(e.ec2, tags, resourceIds...), "updating tags")
+	return errors.Annotate(tagResources(e.ec2, &e.apiStats, tags, resourceIds...), "updating tags")
 }
 
 // AllInstances is part of the environs.InstanceBroker interface.
@@ -1186,6 +1338,15 @@ func (e *environ) allControllerManagedInstances(controllerUUID string) ([]instan
 	return e.allInstanceIDs(filter)
 }
 
+// allModelInstances returns the IDs of all live instances tagged
+// as belonging to this environment's model.
+func (e *environ) allModelInstances() ([]instance.Id, error) {
+	filter := ec2.NewFilter()
+	filter.Add("instance-state-name", aliveInstanceStates...)
+	e.addModelFilter(filter)
+	return e.allInstanceIDs(filter)
+}
+
 func (e *environ) allInstanceIDs(filter *ec2.Filter) ([]instance.Id, error) {
 	insts, err := e.allInstances(filter)
 	if err != nil {
@@ -1219,6 +1380,17 @@ func (e *environ) Destroy() error {
 	if err := common.Destroy(e); err != nil {
 		return errors.Trace(err)
 	}
+	// common.Destroy finds instances by security group membership.
+	// Instances whose membership was altered out-of-band would be
+	// missed, so sweep up any remaining instances tagged with the
+	// model UUID too.
+	instIds, err := e.allModelInstances()
+	if err != nil {
+		return errors.Annotate(err, "listing instances")
+	}
+	if err := e.terminateInstances(instIds); err != nil {
+		return errors.Annotate(err, "terminating instances")
+	}
 	if err := e.cleanEnvironmentSecurityGroups(); err != nil {
 		return errors.Annotate(err, "cannot delete environment security groups")
 	}
@@ -1254,7 +1426,7 @@ func (e *environ) destroyControllerManagedEnvirons(controllerUUID string) error
 	if err != nil {
 		return errors.Annotate(err, "listing volumes")
 	}
-	errs := destroyVolumes(e.ec2, volIds)
+	errs := destroyVolumes(e.ec2, &e.apiStats, volIds)
 	for i, err := range errs {
 		if err == nil {
 			continue
@@ -1268,7 +1440,7 @@ func (e *environ) destroyControllerManagedEnvirons(controllerUUID string) error
 		return errors.Trace(err)
 	}
 	for _, g := range groups {
-		if err := deleteSecurityGroupInsistently(e.ec2, g, clock.WallClock); err != nil {
+		if err := deleteSecurityGroupInsistently(e.securityGroupCleaner(), g, clock.WallClock); err != nil {
 			return errors.Annotatef(
 				err, "cannot delete security group %q (%q)",
 				g.Name, g.Id,
@@ -1479,13 +1651,71 @@ func (e *environ) cleanEnvironmentSecurityGroups() error {
 	if err != nil {
 		return errors.Annotatef(err, "cannot retrieve default security group: %q", jujuGroup)
 	}
-	if err := deleteSecurityGroupInsistently(e.ec2, g, clock.WallClock); err != nil {
+	if err := deleteSecurityGroupInsistently(e.securityGroupCleaner(), g, clock.WallClock); err != nil {
 		return errors.Annotate(err, "cannot delete default security group")
 	}
 	return nil
 }
 
+const (
+	// maxTerminateInstancesBatch is the maximum number of instance
+	// IDs that EC2 accepts in a single TerminateInstances request.
+	maxTerminateInstancesBatch = 1000
+
+	// maxConcurrentTerminations is the maximum number of batches
+	// of instances that terminateInstances terminates concurrently.
+	maxConcurrentTerminations = 4
+)
+
+// terminateInstances terminates the instances with the given IDs. If
+// there are more than EC2 accepts in one request, they are terminated
+// in concurrent batches; all batches are attempted, and their errors
+// combined.
 func (e *environ) terminateInstances(ids []instance.Id) error {
+	if len(ids) <= maxTerminateInstancesBatch {
+		return e.terminateInstanceBatch(ids)
+	}
+	var batches [][]instance.Id
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > maxTerminateInstancesBatch {
+			n = maxTerminateInstancesBatch
+		}
+		batches = append(batches, ids[:n])
+		ids = ids[n:]
+	}
+
+	errs := make([]error, len(batches))
+	sem := make(chan struct{}, maxConcurrentTerminations)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []instance.Id) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = e.terminateInstanceBatch(batch)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	// Return the first error, so that its cause is preserved for
+	// the caller; any others are logged.
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+			continue
+		}
+		logger.Errorf("terminating instances %v: %v", batches[i], err)
+	}
+	return errors.Trace(firstErr)
+}
+
+func (e *environ) terminateInstanceBatch(ids []instance.Id) error {
 	if len(ids) == 0 {
 		return nil
 	}
@@ -1500,14 +1730,19 @@ func (e *environ) terminateInstances(ids []instance.Id) error {
 	// from retry with exponential delay just like security groups
 	// in defer. Bug#1567179.
 	var err error
-	for a := shortAttempt.Start(); a.Next(); {
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
 		_, err = terminateInstancesById(e.ec2, ids...)
-		if err == nil || ec2ErrCode(err) != "InvalidInstanceID.NotFound" {
+		e.apiStats.record("TerminateInstances", i > 0, err)
+		if err == nil || (ec2ErrCode(err) != "InvalidInstanceID.NotFound" && !isTransientError(err)) {
 			// This will return either success at terminating all instances (1st condition) or
-			// encountered error as long as it's not NotFound (2nd condition).
+			// encountered error as long as it's neither NotFound nor transient (2nd condition).
 			return err
 		}
 	}
+	if ec2ErrCode(err) != "InvalidInstanceID.NotFound" {
+		// We ran out of attempts while retrying a transient error.
+		return err
+	}
 
 	// We will get here only if we got a NotFound error.
 	// 1. If we attempted to terminate only one instance was, return now.
@@ -1521,6 +1756,7 @@ func (e *environ) terminateInstances(ids []instance.Id) error {
 	deletedIDs := []instance.Id{}
 	for _, id := range ids {
 		_, err = terminateInstancesById(e.ec2, id)
+		e.apiStats.record("TerminateInstances", false, err)
 		if err == nil {
 			deletedIDs = append(deletedIDs, id)
 		}
@@ -1543,6 +1779,52 @@ var terminateInstancesById = func(ec2inst *ec2.EC2, ids ...instance.Id) (*ec2.Te
 	return ec2inst.TerminateInstances(strs)
 }
 
+// RebootInstances reboots the instances with the given ids. Instances
+// that no longer exist are ignored.
+func (e *environ) RebootInstances(ids []instance.Id) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var err error
+	for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
+		_, err = rebootInstancesById(e.ec2, ids...)
+		e.apiStats.record("RebootInstances", i > 0, err)
+		if err == nil || (ec2ErrCode(err) != "InvalidInstanceID.NotFound" && !isTransientError(err)) {
+			return errors.Annotate(err, "rebooting instances")
+		}
+	}
+	if ec2ErrCode(err) != "InvalidInstanceID.NotFound" {
+		// We ran out of attempts while retrying a transient error.
+		return errors.Annotate(err, "rebooting instances")
+	}
+	if len(ids) == 1 {
+		return nil
+	}
+	// At least one of the instances was not found, in which case
+	// none of them were rebooted. Reboot each instance individually,
+	// ignoring those that are gone.
+	var failed []string
+	for _, id := range ids {
+		_, err := rebootInstancesById(e.ec2, id)
+		e.apiStats.record("RebootInstances", false, err)
+		if err != nil && ec2ErrCode(err) != "InvalidInstanceID.NotFound" {
+			failed = append(failed, fmt.Sprintf("%s: %v", id, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("rebooting instances: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+var rebootInstancesById = func(ec2inst *ec2.EC2, ids ...instance.Id) (*ec2.SimpleResp, error) {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = string(id)
+	}
+	return ec2inst.RebootInstances(strs...)
+}
+
 func (e *environ) deleteSecurityGroupsForInstances(ids []instance.Id) {
 	if len(ids) == 0 {
 		logger.Debugf("no need to delete security groups: no intances were terminated successfully")
@@ -1567,7 +1849,7 @@ func (e *environ) deleteSecurityGroupsForInstances(ids []instance.Id) {
 		if deletable.Name == jujuGroup {
 			continue
 		}
-		if err := deleteSecurityGroupInsistently(e.ec2, deletable, clock.WallClock); err != nil {
+		if err := deleteSecurityGroupInsistently(e.securityGroupCleaner(), deletable, clock.WallClock); err != nil {
 			// In ideal world, we would err out here.
 			// However:
 			// 1. We do not know if all instances have been terminated.
@@ -1702,15 +1984,19 @@ func (e *environ) securityGroupsByNameOrID(groupName string) (*ec2.SecurityGroup
 		filter := ec2.NewFilter()
 		filter.Add("vpc-id", chosenVPCID)
 		filter.Add("group-name", groupName)
-		return e.ec2.SecurityGroups(nil, filter)
+		return fetchSecurityGroups(e.ec2, nil, filter)
 	}
 
 	// EC2-Classic or EC2-VPC with implicit default VPC need to use the
 	// GroupName.X arguments instead of the filters.
 	groups := ec2.SecurityGroupNames(groupName)
-	return e.ec2.SecurityGroups(groups, nil)
+	return fetchSecurityGroups(e.ec2, groups, nil)
 }
 
+// fetchSecurityGroups is used by securityGroupsByNameOrID, and may be
+// replaced for testing.
+var fetchSecurityGroups = (*ec2.EC2).SecurityGroups
+
 // ensureGroup returns the security group with name and perms.
 // If a group with name does not exist, one will be created.
 // If it exists, its permissions are set to perms.
@@ -1741,18 +2027,31 @@ func (e *environ) ensureGroup(controllerUUID, name string, perms []ec2.IPPerm) (
 			names.NewControllerTag(controllerUUID),
 			cfg,
 		)
-		if err := tagResources(e.ec2, tags, g.Id); err != nil {
+		if err := tagResources(e.ec2, &e.apiStats, tags, g.Id); err != nil {
 			return g, errors.Annotate(err, "tagging security group")
 		}
 		logger.Debugf("created security group %q with ID %q%s", name, g.Id, inVPCLogSuffix)
 	} else {
-		resp, err := e.securityGroupsByNameOrID(name)
+		// The group may be in the process of being created by
+		// another caller, in which case it will not necessarily
+		// be visible yet; retry the lookup for a short while.
+		var resp *ec2.SecurityGroupsResp
+		for i, a := 0, shortAttempt.Start(); a.Next(); i++ {
+			resp, err = e.securityGroupsByNameOrID(name)
+			e.apiStats.record("DescribeSecurityGroups", i > 0, err)
+			if err != nil || len(resp.Groups) > 0 {
+				break
+			}
+			logger.Debugf("security group %q%s exists but is not yet visible", name, inVPCLogSuffix)
+		}
 		if err != nil {
 			err = errors.Annotatef(err, "fetching security group %q%s", name, inVPCLogSuffix)
 			return zeroGroup, err
 		}
 		if len(resp.Groups) == 0 {
-			return zeroGroup, errors.NotFoundf("security group %q%s", name, inVPCLogSuffix)
+			return zeroGroup, errors.NewNotFound(nil, fmt.Sprintf(
+				"security group %q%s already exists but is not visible", name, inVPCLogSuffix,
+			))
 		}
 		info := resp.Groups[0]
 		// It's possible that the old group has the wrong
@@ -1902,6 +2201,25 @@ func isSubnetConstrainedError(err error) bool {
 	return false
 }
 
+// isTransientError reports whether or not the error is an EC2 error
+// that is likely to be resolved by retrying the request after a short
+// delay, such as API throttling, a temporary lack of capacity, or a
+// resource not yet being visible due to eventual consistency. Errors
+// such as AuthFailure or InvalidAMIID.NotFound are permanent, and
+// are not considered transient.
+func isTransientError(err error) bool {
+	switch ec2ErrCode(err) {
+	case "InvalidGroup.NotFound",
+		"RequestLimitExceeded",
+		"InsufficientInstanceCapacity",
+		"InternalError",
+		"ServiceUnavailable",
+		"Unavailable":
+		return true
+	}
+	return false
+}
+
 // If the err is of type *ec2.Error, ec2ErrCode returns
 // its code, otherwise it returns the empty string.
 func ec2ErrCode(err error) string {
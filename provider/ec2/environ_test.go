@@ -198,3 +198,49 @@ func (*Suite) TestSupportsContainerAddresses(c *gc.C) {
 	c.Assert(supported, jc.IsFalse)
 	c.Check(env, gc.Not(jc.Satisfies), environs.SupportsContainerAddresses)
 }
+
+func (*Suite) TestIsTransientError(c *gc.C) {
+	for _, code := range []string{
+		"InvalidGroup.NotFound",
+		"RequestLimitExceeded",
+		"InsufficientInstanceCapacity",
+		"InternalError",
+		"ServiceUnavailable",
+		"Unavailable",
+	} {
+		err := errors.Annotate(&amzec2.Error{Code: code}, "running instance")
+		c.Check(isTransientError(err), jc.IsTrue, gc.Commentf("%s", code))
+	}
+	for _, code := range []string{
+		"AuthFailure",
+		"InvalidAMIID.NotFound",
+		"InvalidInstanceID.NotFound",
+	} {
+		err := &amzec2.Error{Code: code}
+		c.Check(isTransientError(err), jc.IsFalse, gc.Commentf("%s", code))
+	}
+	c.Check(isTransientError(nil), jc.IsFalse)
+	c.Check(isTransientError(errors.New("boom")), jc.IsFalse)
+}
+
+func (*Suite) TestFillInstancesDuplicateReservations(c *gc.C) {
+	var env *environ
+	ids := []instance.Id{"i-1", "i-2"}
+	insts := make([]instance.Instance, len(ids))
+	reservations := []amzec2.Reservation{{
+		Instances: []amzec2.Instance{{InstanceId: "i-1"}},
+	}, {
+		Instances: []amzec2.Instance{{InstanceId: "i-1"}},
+	}}
+
+	c.Assert(env.fillInstances(ids, insts, reservations), jc.IsFalse)
+	c.Assert(insts[0], gc.NotNil)
+	c.Assert(insts[0].Id(), gc.Equals, instance.Id("i-1"))
+	c.Assert(insts[1], gc.IsNil)
+
+	reservations = append(reservations, amzec2.Reservation{
+		Instances: []amzec2.Instance{{InstanceId: "i-2"}},
+	})
+	c.Assert(env.fillInstances(ids, insts, reservations), jc.IsTrue)
+	c.Assert(insts[1].Id(), gc.Equals, instance.Id("i-2"))
+}
@@ -5,6 +5,7 @@ package ec2
 
 import (
 	"strings"
+	"time"
 
 	"gopkg.in/amz.v3/aws"
 	"gopkg.in/amz.v3/ec2"
@@ -41,6 +42,22 @@ func TerminatedInstances(e environs.Environ) ([]instance.Instance, error) {
 	return e.(*environ).AllInstancesByState("shutting-down", "terminated")
 }
 
+func Instance(e environs.Environ, id instance.Id) (instance.Instance, error) {
+	return e.(*environ).Instance(id)
+}
+
+func InstancesInState(e environs.Environ, ids []instance.Id, states ...string) ([]instance.Instance, error) {
+	return e.(*environ).InstancesInState(ids, states...)
+}
+
+func RebootInstances(e environs.Environ, ids []instance.Id) error {
+	return e.(*environ).RebootInstances(ids)
+}
+
+func APIStats(e environs.Environ) map[string]APICallStats {
+	return e.(*environ).APIStats()
+}
+
 func InstanceSecurityGroups(e environs.Environ, ids []instance.Id, states ...string) ([]ec2.SecurityGroup, error) {
 	return e.(*environ).instanceSecurityGroups(ids, states...)
 }
@@ -53,10 +70,23 @@ func AllModelGroups(e environs.Environ) ([]string, error) {
 	return e.(*environ).modelSecurityGroupIDs()
 }
 
+func WaitForInstances(e environs.Environ, ids []instance.Id, timeout time.Duration) ([]instance.Instance, error) {
+	return e.(*environ).WaitForInstances(ids, timeout)
+}
+
+func RefreshInstance(inst instance.Instance) error {
+	return inst.(*ec2Instance).Refresh()
+}
+
+func ExportTopology(e environs.Environ) ([]byte, error) {
+	return e.(*environ).ExportTopology()
+}
+
 var (
 	EC2AvailabilityZones        = &ec2AvailabilityZones
 	AvailabilityZoneAllocations = &availabilityZoneAllocations
 	RunInstances                = &runInstances
+	CheckKeyPair                = &checkKeyPair
 	BlockDeviceNamer            = blockDeviceNamer
 	GetBlockDeviceMappings      = getBlockDeviceMappings
 	IsVPCNotUsableError         = isVPCNotUsableError
@@ -82,6 +112,9 @@ var (
 	DestroyVolumeAttempt           = &destroyVolumeAttempt
 	DeleteSecurityGroupInsistently = &deleteSecurityGroupInsistently
 	TerminateInstancesById         = &terminateInstancesById
+	RebootInstancesById            = &rebootInstancesById
+	FetchSecurityGroups            = &fetchSecurityGroups
+	MaxUserDataSize                = &maxUserDataSize
 )
 
 // FabricateInstance creates a new fictitious instance
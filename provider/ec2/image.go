@@ -35,6 +35,21 @@ func filterImages(images []*imagemetadata.ImageMetadata, ic *instances.InstanceC
 	return imagesByStorage[""]
 }
 
+// explicitImageMetadata returns image metadata describing the image with
+// the given id, for each of the given architectures. It is used in place of
+// the image metadata from simplestreams when the image-id config attribute
+// is set, so that the image is used whatever the instance type chosen.
+func explicitImageMetadata(imageID string, arches []string) []*imagemetadata.ImageMetadata {
+	metadata := make([]*imagemetadata.ImageMetadata, len(arches))
+	for i, arch := range arches {
+		metadata[i] = &imagemetadata.ImageMetadata{
+			Id:   imageID,
+			Arch: arch,
+		}
+	}
+	return metadata
+}
+
 // findInstanceSpec returns an InstanceSpec satisfying the supplied instanceConstraint.
 func findInstanceSpec(
 	controller bool,
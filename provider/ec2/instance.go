@@ -6,6 +6,7 @@ package ec2
 import (
 	"fmt"
 
+	"github.com/juju/errors"
 	"gopkg.in/amz.v3/ec2"
 
 	"github.com/juju/juju/environs/config"
@@ -50,6 +51,18 @@ func (inst *ec2Instance) Status() instance.InstanceStatus {
 
 }
 
+// Refresh re-fetches the instance from EC2, replacing all of its cached
+// details (state, addresses, tags, etc.). If the instance no longer
+// exists, or is no longer alive, environs.ErrNoInstances is returned.
+func (inst *ec2Instance) Refresh() error {
+	refreshed, err := inst.e.Instance(inst.Id())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	inst.Instance = refreshed.(*ec2Instance).Instance
+	return nil
+}
+
 // Addresses implements network.Addresses() returning generic address
 // details for the instance, and requerying the ec2 api if required.
 func (inst *ec2Instance) Addresses() ([]network.Address, error) {
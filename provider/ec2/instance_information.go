@@ -9,6 +9,7 @@ import (
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/instances"
+	"github.com/juju/juju/provider/ec2/internal/ec2instancetypes"
 )
 
 var _ environs.InstanceTypesFetcher = (*environ)(nil)
@@ -29,3 +30,10 @@ func (e *environ) InstanceTypes(c constraints.Value) (instances.InstanceTypesWit
 		CostDivisor:   1000,
 		CostCurrency:  "USD"}, nil
 }
+
+// InstanceTypeCost returns the on-demand price, in USD per hour, of
+// the named instance type in the environ's region.
+func (e *environ) InstanceTypeCost(instanceType string) (float64, error) {
+	cost, err := ec2instancetypes.InstanceTypeCost(e.cloud.Region, instanceType)
+	return cost, errors.Trace(err)
+}
@@ -8,9 +8,15 @@ package ec2instancetypes
 import (
 	"strings"
 
+	"github.com/juju/errors"
+
 	"github.com/juju/juju/environs/instances"
 )
 
+// costDivisor is the number by which InstanceType.Cost must be
+// divided to obtain the on-demand price in USD per hour.
+const costDivisor = 1000
+
 // RegionInstanceTypes returns the instance types for the named region.
 func RegionInstanceTypes(region string) []instances.InstanceType {
 	// NOTE(axw) at the time of writing, there is no cost
@@ -24,6 +30,22 @@ func RegionInstanceTypes(region string) []instances.InstanceType {
 	return instanceTypes
 }
 
+// InstanceTypeCost returns the on-demand price, in USD per hour, of
+// the named instance type in the named region. Unlike
+// RegionInstanceTypes, no substitute is made for unknown regions.
+func InstanceTypeCost(region, instanceType string) (float64, error) {
+	instanceTypes, ok := allInstanceTypes[region]
+	if !ok {
+		return 0, errors.NotFoundf("cost information for region %q", region)
+	}
+	for _, it := range instanceTypes {
+		if it.Name == instanceType {
+			return float64(it.Cost) / costDivisor, nil
+		}
+	}
+	return 0, errors.NotFoundf("instance type %q in region %q", instanceType, region)
+}
+
 // SupportsClassic reports whether the instance type with the given
 // name can be run in EC2-Classic.
 //
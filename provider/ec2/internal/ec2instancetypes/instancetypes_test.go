@@ -4,6 +4,7 @@
 package ec2instancetypes_test
 
 import (
+	"github.com/juju/errors"
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils/set"
@@ -82,6 +83,23 @@ func (s *InstanceTypesSuite) TestRegionInstanceTypesUnknownRegion(c *gc.C) {
 	c.Assert(instanceTypes, jc.DeepEquals, ec2instancetypes.RegionInstanceTypes("us-east-1"))
 }
 
+func (s *InstanceTypesSuite) TestInstanceTypeCost(c *gc.C) {
+	cost, err := ec2instancetypes.InstanceTypeCost("us-east-1", "m3.medium")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cost, gc.Equals, 0.067)
+}
+
+func (s *InstanceTypesSuite) TestInstanceTypeCostUnknownRegion(c *gc.C) {
+	_, err := ec2instancetypes.InstanceTypeCost("cn-north-1", "m3.medium")
+	c.Assert(err, gc.ErrorMatches, `cost information for region "cn-north-1" not found`)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *InstanceTypesSuite) TestInstanceTypeCostUnknownInstanceType(c *gc.C) {
+	_, err := ec2instancetypes.InstanceTypeCost("us-east-1", "m0.tiny")
+	c.Assert(err, gc.ErrorMatches, `instance type "m0.tiny" in region "us-east-1" not found`)
+}
+
 func (s *InstanceTypesSuite) TestSupportsClassic(c *gc.C) {
 	assertSupportsClassic := func(name string) {
 		c.Assert(ec2instancetypes.SupportsClassic(name), jc.IsTrue)
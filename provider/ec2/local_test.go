@@ -4,6 +4,7 @@
 package ec2_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http/httptest"
 	"net/http/httputil"
@@ -12,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
@@ -527,6 +529,174 @@ func (t *localServerSuite) TestTerminateInstancesIgnoresNotFound(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 }
 
+func (t *localServerSuite) TestStopInstancesRetriesTransientError(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	realTerminateInstancesById := *ec2.TerminateInstancesById
+	var calls int
+	t.BaseSuite.PatchValue(ec2.TerminateInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.TerminateInstancesResp, error) {
+		calls++
+		if calls == 1 {
+			return nil, &amzec2.Error{Code: "RequestLimitExceeded"}
+		}
+		return realTerminateInstancesById(ec2inst, ids...)
+	})
+
+	err := env.StopInstances(inst.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calls, gc.Equals, 2)
+}
+
+func (t *localServerSuite) TestStopInstancesBatches(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var mu sync.Mutex
+	var batchSizes []int
+	terminated := set.NewStrings()
+	t.BaseSuite.PatchValue(ec2.TerminateInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.TerminateInstancesResp, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		batchSizes = append(batchSizes, len(ids))
+		for _, id := range ids {
+			terminated.Add(string(id))
+		}
+		return &amzec2.TerminateInstancesResp{}, nil
+	})
+
+	ids := make([]instance.Id, 2500)
+	for i := range ids {
+		ids[i] = instance.Id(fmt.Sprintf("i-%08d", i))
+	}
+	err := env.StopInstances(ids...)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(batchSizes, jc.SameContents, []int{1000, 1000, 500})
+	c.Assert(terminated.Size(), gc.Equals, len(ids))
+}
+
+func (t *localServerSuite) TestStopInstancesBatchesErrors(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	t.BaseSuite.PatchValue(ec2.TerminateInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.TerminateInstancesResp, error) {
+		return nil, &amzec2.Error{Code: "AuthFailure"}
+	})
+
+	ids := make([]instance.Id, 2500)
+	for i := range ids {
+		ids[i] = instance.Id(fmt.Sprintf("i-%08d", i))
+	}
+	err := env.StopInstances(ids...)
+	c.Assert(err, gc.ErrorMatches, ".*AuthFailure.*")
+	// Only one batch's error is returned, with its cause intact.
+	ec2Err, ok := errors.Cause(err).(*amzec2.Error)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(ec2Err.Code, gc.Equals, "AuthFailure")
+}
+
+func (t *localServerSuite) TestStopInstancesPermanentError(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var calls int
+	t.BaseSuite.PatchValue(ec2.TerminateInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.TerminateInstancesResp, error) {
+		calls++
+		return nil, &amzec2.Error{Code: "AuthFailure"}
+	})
+
+	err := env.StopInstances("i-whatever")
+	c.Assert(err, gc.ErrorMatches, ".*AuthFailure.*")
+	c.Assert(calls, gc.Equals, 1)
+}
+
+func (t *localServerSuite) TestRebootInstances(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst1, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	inst2, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+
+	var rebooted [][]instance.Id
+	t.BaseSuite.PatchValue(ec2.RebootInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.SimpleResp, error) {
+		rebooted = append(rebooted, ids)
+		return &amzec2.SimpleResp{}, nil
+	})
+
+	err := ec2.RebootInstances(env, []instance.Id{inst1.Id(), inst2.Id()})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rebooted, jc.DeepEquals, [][]instance.Id{{inst1.Id(), inst2.Id()}})
+}
+
+func (t *localServerSuite) TestAPIStatsThrottled(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	t.PatchValue(ec2.ShortAttempt, utils.AttemptStrategy{Min: 5})
+	var calls int
+	realRebootInstancesById := *ec2.RebootInstancesById
+	t.PatchValue(ec2.RebootInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.SimpleResp, error) {
+		calls++
+		if calls <= 2 {
+			return nil, &amzec2.Error{Code: "RequestLimitExceeded"}
+		}
+		return realRebootInstancesById(ec2inst, ids...)
+	})
+	err := ec2.RebootInstances(env, []instance.Id{inst.Id()})
+	c.Assert(err, jc.ErrorIsNil)
+
+	stats := ec2.APIStats(env)
+	c.Assert(stats["RebootInstances"], jc.DeepEquals, ec2.APICallStats{
+		Requests:  3,
+		Retries:   2,
+		Throttled: 2,
+	})
+}
+
+func (t *localServerSuite) TestAPIStatsRunInstancesThrottled(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	t.PatchValue(ec2.ShortAttempt, utils.AttemptStrategy{Min: 5})
+	var calls int
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
+		calls++
+		if calls <= 2 {
+			return nil, &amzec2.Error{Code: "RequestLimitExceeded"}
+		}
+		return realRunInstances(e, ri)
+	})
+	before := ec2.APIStats(env)["RunInstances"]
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	stats := ec2.APIStats(env)
+	c.Assert(stats["RunInstances"], jc.DeepEquals, ec2.APICallStats{
+		Requests:  before.Requests + 3,
+		Retries:   before.Retries + 2,
+		Throttled: before.Throttled + 2,
+	})
+}
+
+func (t *localServerSuite) TestRebootInstancesIgnoresMissing(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+
+	var rebooted [][]instance.Id
+	t.BaseSuite.PatchValue(ec2.RebootInstancesById, func(ec2inst *amzec2.EC2, ids ...instance.Id) (*amzec2.SimpleResp, error) {
+		rebooted = append(rebooted, ids)
+		for _, id := range ids {
+			switch id {
+			case "i-gone":
+				return nil, &amzec2.Error{Code: "InvalidInstanceID.NotFound"}
+			case "i-broken":
+				return nil, &amzec2.Error{Code: "AuthFailure"}
+			}
+		}
+		return &amzec2.SimpleResp{}, nil
+	})
+
+	err := ec2.RebootInstances(env, []instance.Id{"i-gone", "i-ok"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(rebooted[len(rebooted)-2:], jc.DeepEquals, [][]instance.Id{{"i-gone"}, {"i-ok"}})
+
+	err = ec2.RebootInstances(env, []instance.Id{"i-gone", "i-broken", "i-ok"})
+	c.Assert(err, gc.ErrorMatches, "rebooting instances: i-broken: .*AuthFailure.*")
+}
+
 func (t *localServerSuite) TestDestroyErr(c *gc.C) {
 	env := t.prepareAndBootstrap(c)
 
@@ -539,6 +709,45 @@ func (t *localServerSuite) TestDestroyErr(c *gc.C) {
 	c.Assert(errors.Cause(err).Error(), jc.Contains, msg)
 }
 
+func (t *localServerSuite) TestDestroyTerminatesTaggedInstances(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+
+	// inst1 is started normally, and so is a member of the model's
+	// security group. leaked is not in the group, and is only
+	// identifiable as belonging to the model by its tags.
+	inst1, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	resp, err := t.client.RunInstances(&amzec2.RunInstances{
+		ImageId:  "ami-00000033",
+		MinCount: 1,
+		MaxCount: 1,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(resp.Instances, gc.HasLen, 1)
+	leaked := resp.Instances[0].InstanceId
+	_, err = t.client.CreateTags([]string{leaked}, []amzec2.Tag{{tags.JujuModel, env.Config().UUID()}})
+	c.Assert(err, jc.ErrorIsNil)
+
+	insts, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	for _, inst := range insts {
+		c.Assert(string(inst.Id()), gc.Not(gc.Equals), leaked)
+	}
+
+	err = env.Destroy()
+	c.Assert(err, jc.ErrorIsNil)
+
+	instsResp, err := t.client.Instances([]string{string(inst1.Id()), leaked}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	var states []string
+	for _, r := range instsResp.Reservations {
+		for _, inst := range r.Instances {
+			states = append(states, inst.State.Name)
+		}
+	}
+	c.Assert(states, jc.DeepEquals, []string{"terminated", "terminated"})
+}
+
 func (t *localServerSuite) TestGetTerminatedInstances(c *gc.C) {
 	env := t.Prepare(c)
 	err := bootstrap.Bootstrap(envtesting.BootstrapContext(c), env, bootstrap.BootstrapParams{
@@ -717,6 +926,94 @@ func (t *localServerSuite) TestInstanceStatus(c *gc.C) {
 	c.Assert(inst.Status().Message, gc.Equals, "terminated")
 }
 
+func (t *localServerSuite) TestInstance(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	found, err := ec2.Instance(env, inst.Id())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(found.Id(), gc.Equals, inst.Id())
+
+	_, err = ec2.Instance(env, "i-missing")
+	c.Assert(err, gc.Equals, environs.ErrNoInstances)
+}
+
+func (t *localServerSuite) TestInstancesInState(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Terminated)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	// Instances only reports pending and running instances.
+	_, err := env.Instances([]instance.Id{inst.Id()})
+	c.Assert(err, gc.Equals, environs.ErrNoInstances)
+
+	insts, err := ec2.InstancesInState(env, []instance.Id{inst.Id()}, "stopped", "terminated")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 1)
+	c.Assert(insts[0].Id(), gc.Equals, inst.Id())
+}
+
+func (t *localServerSuite) TestInstancesInStateInvalid(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	_, err := ec2.InstancesInState(env, []instance.Id{"i-whatever"}, "running", "sleeping")
+	c.Assert(err, gc.ErrorMatches, `instance state "sleeping" not valid`)
+	_, err = ec2.InstancesInState(env, []instance.Id{"i-whatever"})
+	c.Assert(err, gc.ErrorMatches, "no instance states specified")
+}
+
+func (t *localServerSuite) TestWaitForInstances(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+	inst1, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	inst2, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+
+	insts, err := ec2.WaitForInstances(env, []instance.Id{inst2.Id(), inst1.Id()}, coretesting.LongWait)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 2)
+	c.Assert(insts[0].Id(), gc.Equals, inst2.Id())
+	c.Assert(insts[1].Id(), gc.Equals, inst1.Id())
+}
+
+func (t *localServerSuite) TestWaitForInstancesTimeout(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Pending)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	_, err := ec2.WaitForInstances(env, []instance.Id{inst.Id()}, coretesting.ShortWait)
+	c.Assert(err, gc.ErrorMatches, "timed out waiting for instances to be running: "+string(inst.Id()))
+}
+
+func (t *localServerSuite) TestWaitForInstancesTerminated(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Running)
+	inst1, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	t.srv.ec2srv.SetInitialInstanceState(ec2test.Terminated)
+	inst2, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "2")
+
+	_, err := ec2.WaitForInstances(env, []instance.Id{inst1.Id(), inst2.Id()}, coretesting.LongWait)
+	c.Assert(err, gc.ErrorMatches, "instances not running: "+string(inst2.Id())+` \(terminated\)`)
+}
+
+func (t *localServerSuite) TestInstanceRefresh(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+
+	_, err := t.client.CreateTags([]string{string(inst.Id())}, []amzec2.Tag{{"refreshed", "yes"}})
+	c.Assert(err, jc.ErrorIsNil)
+	err = ec2.RefreshInstance(inst)
+	c.Assert(err, jc.ErrorIsNil)
+	tags := make(map[string]string)
+	for _, tag := range ec2.InstanceEC2(inst).Tags {
+		tags[tag.Key] = tag.Value
+	}
+	c.Assert(tags["refreshed"], gc.Equals, "yes")
+
+	_, err = t.client.TerminateInstances([]string{string(inst.Id())})
+	c.Assert(err, jc.ErrorIsNil)
+	err = ec2.RefreshInstance(inst)
+	c.Assert(errors.Cause(err), gc.Equals, environs.ErrNoInstances)
+}
+
 func (t *localServerSuite) TestStartInstanceHardwareCharacteristics(c *gc.C) {
 	env := t.prepareAndBootstrap(c)
 	_, hc := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
@@ -725,6 +1022,118 @@ func (t *localServerSuite) TestStartInstanceHardwareCharacteristics(c *gc.C) {
 	c.Check(*hc.CpuCores, gc.Equals, uint64(1))
 }
 
+func (t *localServerSuite) TestStartInstanceUserDataTooLarge(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.PatchValue(ec2.MaxUserDataSize, 10)
+	params := environs.StartInstanceParams{ControllerUUID: t.ControllerUUID, StatusCallback: fakeCallback}
+	_, err := testing.StartInstanceWithParams(env, "1", params)
+	c.Assert(err, gc.ErrorMatches, `user data is \d+ bytes, exceeding the EC2 limit of 10 bytes`)
+}
+
+func (t *localServerSuite) TestStartInstanceImageID(c *gc.C) {
+	env := t.prepareAndBootstrapWithConfig(c, coretesting.Attrs{"image-id": "ami-a1b2c3d4"})
+	inst, _ := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(ec2.InstanceEC2(inst).ImageId, gc.Equals, "ami-a1b2c3d4")
+}
+
+func (t *localServerSuite) TestStartInstanceIAMInstanceProfile(c *gc.C) {
+	env := t.prepareAndBootstrapWithConfig(c, coretesting.Attrs{
+		"iam-instance-profile": "arn:aws:iam::123456789012:instance-profile/juju-instance-profile",
+	})
+	var profiles []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
+		profiles = append(profiles, ri.IamInstanceProfile)
+		return realRunInstances(e, ri)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(profiles, gc.DeepEquals, []string{"juju-instance-profile"})
+}
+
+func (t *localServerSuite) TestStartInstanceKeyName(c *gc.C) {
+	var checked []string
+	t.PatchValue(ec2.CheckKeyPair, func(_ *amzec2.EC2, keyName string) error {
+		checked = append(checked, keyName)
+		return nil
+	})
+	env := t.prepareAndBootstrapWithConfig(c, coretesting.Attrs{"key-name": "break-glass"})
+	c.Assert(checked, gc.Not(gc.HasLen), 0)
+	for _, keyName := range checked {
+		c.Assert(keyName, gc.Equals, "break-glass")
+	}
+
+	var keyNames []string
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
+		keyNames = append(keyNames, ri.KeyName)
+		return realRunInstances(e, ri)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(keyNames, gc.DeepEquals, []string{"break-glass"})
+}
+
+func (t *localServerSuite) TestStartInstanceMonitoring(c *gc.C) {
+	t.testStartInstanceMonitoring(c, coretesting.Attrs{"enable-monitoring": true}, true)
+}
+
+func (t *localServerSuite) TestStartInstanceMonitoringDefault(c *gc.C) {
+	t.testStartInstanceMonitoring(c, nil, false)
+}
+
+func (t *localServerSuite) testStartInstanceMonitoring(c *gc.C, attrs coretesting.Attrs, expect bool) {
+	env := t.prepareAndBootstrapWithConfig(c, attrs)
+	var monitoring []bool
+	realRunInstances := *ec2.RunInstances
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
+		monitoring = append(monitoring, ri.Monitoring)
+		return realRunInstances(e, ri)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(monitoring, gc.DeepEquals, []bool{expect})
+}
+
+func (t *localServerSuite) TestOpenKeyNameNotFound(c *gc.C) {
+	t.PatchValue(ec2.CheckKeyPair, func(_ *amzec2.EC2, keyName string) error {
+		return errors.NotFoundf("key pair %q", keyName)
+	})
+	env := t.Prepare(c)
+	cfg, err := env.Config().Apply(map[string]interface{}{"key-name": "missing"})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = environs.New(environs.OpenParams{
+		Cloud:  t.CloudSpec(),
+		Config: cfg,
+	})
+	c.Assert(err, gc.ErrorMatches, `key pair "missing" not found`)
+}
+
+func (t *localServerSuite) TestStartInstanceGroupNotYetVisible(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.PatchValue(ec2.ShortAttempt, utils.AttemptStrategy{Min: 3})
+	var calls int
+	realFetchSecurityGroups := *ec2.FetchSecurityGroups
+	t.PatchValue(ec2.FetchSecurityGroups, func(e *amzec2.EC2, groups []amzec2.SecurityGroup, filter *amzec2.Filter) (*amzec2.SecurityGroupsResp, error) {
+		calls++
+		if calls == 1 {
+			// Simulate the group having been created
+			// concurrently, but not yet being visible.
+			return &amzec2.SecurityGroupsResp{}, nil
+		}
+		return realFetchSecurityGroups(e, groups, filter)
+	})
+	testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
+	c.Assert(calls, jc.GreaterThan, 1)
+}
+
+func (t *localServerSuite) TestStartInstanceGroupNeverVisible(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	t.PatchValue(ec2.FetchSecurityGroups, func(*amzec2.EC2, []amzec2.SecurityGroup, *amzec2.Filter) (*amzec2.SecurityGroupsResp, error) {
+		return &amzec2.SecurityGroupsResp{}, nil
+	})
+	params := environs.StartInstanceParams{ControllerUUID: t.ControllerUUID, StatusCallback: fakeCallback}
+	_, err := testing.StartInstanceWithParams(env, "1", params)
+	c.Assert(err, gc.ErrorMatches, `.*security group "juju-.*" already exists but is not visible`)
+}
+
 func (t *localServerSuite) TestStartInstanceAvailZone(c *gc.C) {
 	inst, err := t.testStartInstanceAvailZone(c, "test-available")
 	c.Assert(err, jc.ErrorIsNil)
@@ -1041,7 +1450,7 @@ func (t *localServerSuite) testStartInstanceAvailZoneAllConstrained(c *gc.C, run
 
 	var azArgs []string
 
-	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
 		azArgs = append(azArgs, ri.AvailZone)
 		return nil, runInstancesError
 	})
@@ -1215,12 +1624,12 @@ func (t *localServerSuite) testStartInstanceAvailZoneOneConstrained(c *gc.C, run
 	var azArgs []string
 	realRunInstances := *ec2.RunInstances
 
-	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances, c environs.StatusCallbackFunc) (*amzec2.RunInstancesResp, error) {
+	t.PatchValue(ec2.RunInstances, func(e *amzec2.EC2, ri *amzec2.RunInstances) (*amzec2.RunInstancesResp, error) {
 		azArgs = append(azArgs, ri.AvailZone)
 		if len(azArgs) == 1 {
 			return nil, runInstancesError
 		}
-		return realRunInstances(e, ri, fakeCallback)
+		return realRunInstances(e, ri)
 	})
 	inst, hwc := testing.AssertStartInstance(c, env, t.ControllerUUID, "1")
 	c.Assert(azArgs, gc.DeepEquals, []string{"az1", "az2"})
@@ -1647,6 +2056,59 @@ func (t *localServerSuite) TestInstanceTags(c *gc.C) {
 	})
 }
 
+func (t *localServerSuite) TestInstanceResourceTags(c *gc.C) {
+	env := t.prepareAndBootstrapWithConfig(c, coretesting.Attrs{
+		"resource-tags": "cost-center=1234 team=infra",
+	})
+
+	instances, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(instances, gc.HasLen, 1)
+
+	ec2Inst := ec2.InstanceEC2(instances[0])
+	c.Assert(ec2Inst.Tags, jc.SameContents, []amzec2.Tag{
+		{"Name", "juju-sample-machine-0"},
+		{"juju-model-uuid", coretesting.ModelTag.Id()},
+		{"juju-controller-uuid", t.ControllerUUID},
+		{"juju-is-controller", "true"},
+		{"cost-center", "1234"},
+		{"team", "infra"},
+	})
+}
+
+func (t *localServerSuite) TestExportTopology(c *gc.C) {
+	env := t.prepareAndBootstrap(c)
+	insts, err := env.AllInstances()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(insts, gc.HasLen, 1)
+
+	data, err := ec2.ExportTopology(env)
+	c.Assert(err, jc.ErrorIsNil)
+	var topology ec2.Topology
+	err = json.Unmarshal(data, &topology)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(topology.Errors, gc.HasLen, 0)
+
+	c.Assert(topology.Instances, gc.HasLen, 1)
+	c.Assert(topology.Instances[0].Id, gc.Equals, string(insts[0].Id()))
+	c.Assert(topology.Instances[0].Controller, jc.IsTrue)
+	c.Assert(topology.Instances[0].Tags["juju-model-uuid"], gc.Equals, coretesting.ModelTag.Id())
+	c.Assert(topology.Instances[0].SecurityGroups, jc.SameContents, []string{
+		"juju-" + coretesting.ModelTag.Id(),
+		"juju-" + coretesting.ModelTag.Id() + "-0",
+	})
+
+	groupNames := make([]string, len(topology.SecurityGroups))
+	for i, group := range topology.SecurityGroups {
+		groupNames[i] = group.Name
+	}
+	c.Assert(groupNames, jc.DeepEquals, []string{
+		"juju-" + coretesting.ModelTag.Id(),
+		"juju-" + coretesting.ModelTag.Id() + "-0",
+	})
+	c.Assert(topology.SecurityGroups[0].Rules, gc.Not(gc.HasLen), 0)
+}
+
 func (t *localServerSuite) TestRootDiskTags(c *gc.C) {
 	env := t.prepareAndBootstrap(c)
 
@@ -5,6 +5,8 @@ package ec2
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/juju/errors"
@@ -58,9 +60,34 @@ func (p environProvider) Open(args environs.OpenParams) (environs.Environ, error
 	if err := e.SetConfig(args.Config); err != nil {
 		return nil, errors.Trace(err)
 	}
+	if keyName := e.ecfg().keyName(); keyName != "" {
+		if err := checkKeyPair(e.ec2, keyName); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	return e, nil
 }
 
+var checkKeyPair = _checkKeyPair
+
+// checkKeyPair returns an error if the EC2 key pair with the given name
+// does not exist. If the key pairs cannot be listed, a warning is
+// logged and no error is returned.
+func _checkKeyPair(client *ec2.EC2, keyName string) error {
+	resp, err := client.KeyPairs([]string{keyName}, nil)
+	if err != nil {
+		if ec2ErrCode(err) == "InvalidKeyPair.NotFound" {
+			return errors.NotFoundf("key pair %q", keyName)
+		}
+		logger.Warningf("cannot check for key pair %q: %v", keyName, err)
+		return nil
+	}
+	if len(resp.Keys) == 0 {
+		return errors.NotFoundf("key pair %q", keyName)
+	}
+	return nil
+}
+
 // isBrokenCloud reports whether the given CloudSpec is from an old,
 // broken version of public-clouds.yaml.
 func isBrokenCloud(cloud environs.CloudSpec) bool {
@@ -140,6 +167,14 @@ func validateCloudSpec(c environs.CloudSpec) error {
 	if authType := c.Credential.AuthType(); authType != cloud.AccessKeyAuthType {
 		return errors.NotSupportedf("%q auth-type", authType)
 	}
+	if c.Endpoint != "" {
+		// The endpoint may name an EC2-compatible service other
+		// than AWS, such as a local mock; it must be a full URL.
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.NotValidf("endpoint %q", c.Endpoint)
+		}
+	}
 	return nil
 }
 
@@ -160,7 +195,7 @@ func (p environProvider) MetadataLookupParams(region string) (*simplestreams.Met
 	}
 	ec2Region, ok := aws.Regions[region]
 	if !ok {
-		return nil, fmt.Errorf("unknown region %q", region)
+		return nil, unknownRegionError(region)
 	}
 	return &simplestreams.MetadataLookupParams{
 		Region:   region,
@@ -168,6 +203,26 @@ func (p environProvider) MetadataLookupParams(region string) (*simplestreams.Met
 	}, nil
 }
 
+// SupportedRegions returns the sorted names of the EC2 regions
+// for which the provider knows an endpoint.
+func SupportedRegions() []string {
+	var regions []string
+	for name, region := range aws.Regions {
+		if region.EC2Endpoint != "" {
+			regions = append(regions, name)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+func unknownRegionError(region string) error {
+	return errors.Errorf(
+		"unknown region %q, expected one of: %s",
+		region, strings.Join(SupportedRegions(), ", "),
+	)
+}
+
 const badAccessKey = `
 Please ensure the Access Key ID you have specified is correct.
 You can obtain the Access Key ID via the "Security Credentials"
@@ -11,6 +11,7 @@ import (
 
 	"github.com/juju/juju/cloud"
 	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/simplestreams"
 	"github.com/juju/juju/provider/ec2"
 	coretesting "github.com/juju/juju/testing"
 )
@@ -106,6 +107,49 @@ func (s *ProviderSuite) TestOpenKnownRegionValidEndpoint(c *gc.C) {
 	c.Assert(ec2Client.Region.EC2Endpoint, gc.Equals, "https://ec2.us-east-1.amazonaws.com")
 }
 
+func (s *ProviderSuite) TestOpenCustomEndpoint(c *gc.C) {
+	// An EC2-compatible service other than AWS may be used
+	// by specifying its endpoint in the cloud definition.
+	s.spec.Endpoint = "http://localhost:4566"
+
+	env, err := s.provider.Open(environs.OpenParams{
+		Cloud:  s.spec,
+		Config: coretesting.ModelConfig(c),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	ec2Client := ec2.EnvironEC2(env)
+	c.Assert(ec2Client.Region.EC2Endpoint, gc.Equals, "http://localhost:4566")
+}
+
+func (s *ProviderSuite) TestOpenInvalidEndpoint(c *gc.C) {
+	s.spec.Endpoint = "localhost:4566"
+	s.testOpenError(c, s.spec, `validating cloud spec: endpoint "localhost:4566" not valid`)
+}
+
+func (s *ProviderSuite) TestSupportedRegions(c *gc.C) {
+	s.PatchValue(&aws.Regions, map[string]aws.Region{
+		"us-west-2":  {EC2Endpoint: "https://ec2.us-west-2.amazonaws.com"},
+		"eu-west-1":  {EC2Endpoint: "https://ec2.eu-west-1.amazonaws.com"},
+		"no-ec2-yet": {},
+	})
+	c.Assert(ec2.SupportedRegions(), jc.DeepEquals, []string{"eu-west-1", "us-west-2"})
+}
+
+func (s *ProviderSuite) TestMetadataLookupParamsUnknownRegion(c *gc.C) {
+	s.PatchValue(&aws.Regions, map[string]aws.Region{
+		"us-west-2": {EC2Endpoint: "https://ec2.us-west-2.amazonaws.com"},
+		"eu-west-1": {EC2Endpoint: "https://ec2.eu-west-1.amazonaws.com"},
+	})
+	env, err := s.provider.Open(environs.OpenParams{
+		Cloud:  s.spec,
+		Config: coretesting.ModelConfig(c),
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = env.(simplestreams.MetadataValidator).MetadataLookupParams("foobar")
+	c.Assert(err, gc.ErrorMatches, `unknown region "foobar", expected one of: eu-west-1, us-west-2`)
+}
+
 func (s *ProviderSuite) TestOpenMissingCredential(c *gc.C) {
 	s.spec.Credential = nil
 	s.testOpenError(c, s.spec, `validating cloud spec: missing credential not valid`)
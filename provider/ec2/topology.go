@@ -0,0 +1,149 @@
+// Copyright 2017 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package ec2
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/juju/errors"
+	"gopkg.in/amz.v3/ec2"
+
+	"github.com/juju/juju/environs/tags"
+)
+
+// Topology is a read-only snapshot of the EC2 resources that make up
+// a model. It is intended to be archived by operators, and used as a
+// guide when manually rebuilding a model after a disaster.
+type Topology struct {
+	Instances      []TopologyInstance      `json:"instances"`
+	SecurityGroups []TopologySecurityGroup `json:"security-groups"`
+
+	// Errors records any problems encountered while gathering
+	// the topology. A non-empty Errors indicates that the
+	// snapshot is incomplete.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// TopologyInstance describes an instance in a Topology.
+type TopologyInstance struct {
+	Id               string            `json:"id"`
+	Type             string            `json:"type"`
+	State            string            `json:"state"`
+	AvailabilityZone string            `json:"availability-zone,omitempty"`
+	PrivateAddress   string            `json:"private-address,omitempty"`
+	PublicAddress    string            `json:"public-address,omitempty"`
+	SecurityGroups   []string          `json:"security-groups,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Controller       bool              `json:"controller"`
+}
+
+// TopologySecurityGroup describes a security group in a Topology.
+type TopologySecurityGroup struct {
+	Id    string         `json:"id"`
+	Name  string         `json:"name"`
+	Rules []TopologyRule `json:"rules,omitempty"`
+}
+
+// TopologyRule describes an ingress rule of a security group.
+type TopologyRule struct {
+	Protocol     string   `json:"protocol"`
+	FromPort     int      `json:"from-port"`
+	ToPort       int      `json:"to-port"`
+	SourceCIDRs  []string `json:"source-cidrs,omitempty"`
+	SourceGroups []string `json:"source-groups,omitempty"`
+}
+
+// ExportTopology gathers the instances and security groups belonging
+// to the model, and returns them serialised as a JSON document. Failure
+// to gather any one section does not prevent the others from being
+// exported; such failures are recorded in the document's errors.
+func (e *environ) ExportTopology() ([]byte, error) {
+	topology := Topology{
+		Instances:      []TopologyInstance{},
+		SecurityGroups: []TopologySecurityGroup{},
+	}
+
+	insts, err := e.AllInstances()
+	if err != nil {
+		topology.Errors = append(topology.Errors, fmt.Sprintf("listing instances: %v", err))
+	}
+	for _, inst := range insts {
+		topology.Instances = append(topology.Instances, topologyInstance(inst.(*ec2Instance).Instance))
+	}
+	sort.Sort(byInstanceId(topology.Instances))
+
+	filter := ec2.NewFilter()
+	e.addModelFilter(filter)
+	resp, err := e.ec2.SecurityGroups(nil, filter)
+	if err != nil {
+		topology.Errors = append(topology.Errors, fmt.Sprintf("listing security groups: %v", err))
+	} else {
+		for _, group := range resp.Groups {
+			topology.SecurityGroups = append(topology.SecurityGroups, topologySecurityGroup(group))
+		}
+	}
+	sort.Sort(byGroupName(topology.SecurityGroups))
+
+	data, err := json.MarshalIndent(topology, "", "  ")
+	if err != nil {
+		return nil, errors.Annotate(err, "serialising topology")
+	}
+	return data, nil
+}
+
+func topologyInstance(inst *ec2.Instance) TopologyInstance {
+	result := TopologyInstance{
+		Id:               inst.InstanceId,
+		Type:             inst.InstanceType,
+		State:            inst.State.Name,
+		AvailabilityZone: inst.AvailZone,
+		PrivateAddress:   inst.PrivateIPAddress,
+		PublicAddress:    inst.IPAddress,
+	}
+	for _, group := range inst.SecurityGroups {
+		result.SecurityGroups = append(result.SecurityGroups, group.Name)
+	}
+	if len(inst.Tags) > 0 {
+		result.Tags = make(map[string]string)
+		for _, tag := range inst.Tags {
+			result.Tags[tag.Key] = tag.Value
+		}
+		result.Controller = result.Tags[tags.JujuIsController] == "true"
+	}
+	return result
+}
+
+func topologySecurityGroup(group ec2.SecurityGroupInfo) TopologySecurityGroup {
+	result := TopologySecurityGroup{
+		Id:   group.Id,
+		Name: group.Name,
+	}
+	for _, perm := range group.IPPerms {
+		rule := TopologyRule{
+			Protocol:    perm.Protocol,
+			FromPort:    perm.FromPort,
+			ToPort:      perm.ToPort,
+			SourceCIDRs: perm.SourceIPs,
+		}
+		for _, source := range perm.SourceGroups {
+			rule.SourceGroups = append(rule.SourceGroups, source.Id)
+		}
+		result.Rules = append(result.Rules, rule)
+	}
+	return result
+}
+
+type byInstanceId []TopologyInstance
+
+func (s byInstanceId) Len() int           { return len(s) }
+func (s byInstanceId) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byInstanceId) Less(i, j int) bool { return s[i].Id < s[j].Id }
+
+type byGroupName []TopologySecurityGroup
+
+func (s byGroupName) Len() int           { return len(s) }
+func (s byGroupName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byGroupName) Less(i, j int) bool { return s[i].Name < s[j].Name }
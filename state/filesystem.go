@@ -149,6 +149,12 @@ type FilesystemInfo struct {
 	// filesystem. This will be unspecified for filesystems
 	// backed by volumes.
 	FilesystemId string `bson:"filesystemid"`
+
+	// UUID is the UUID of the filesystem, if known.
+	UUID string `bson:"uuid,omitempty"`
+
+	// Label is the label of the filesystem, if it has one.
+	Label string `bson:"label,omitempty"`
 }
 
 // FilesystemAttachmentInfo describes information about a filesystem attachment.
@@ -130,6 +130,29 @@ func (s *FilesystemStateSuite) TestSetFilesystemInfoImmutable(c *gc.C) {
 	s.assertFilesystemInfo(c, filesystemTag, filesystemInfoSet)
 }
 
+func (s *FilesystemStateSuite) TestSetFilesystemInfoUUIDAndLabel(c *gc.C) {
+	_, u, storageTag := s.setupSingleStorage(c, "filesystem", "rootfs")
+	err := s.State.AssignUnit(u, state.AssignCleanEmpty)
+	c.Assert(err, jc.ErrorIsNil)
+	filesystem := s.storageInstanceFilesystem(c, storageTag)
+
+	machine := unitMachine(c, s.State, u)
+	err = machine.SetProvisioned("inst-id", "fake_nonce", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	filesystemInfoSet := state.FilesystemInfo{
+		Size:         123,
+		FilesystemId: "fs-id",
+		UUID:         "0b56138b-6124-4ec4-a7a3-7c503516a65c",
+		Label:        "juju-data",
+	}
+	err = s.State.SetFilesystemInfo(filesystem.FilesystemTag(), filesystemInfoSet)
+	c.Assert(err, jc.ErrorIsNil)
+
+	filesystemInfoSet.Pool = "rootfs"
+	s.assertFilesystemInfo(c, filesystem.FilesystemTag(), filesystemInfoSet)
+}
+
 func (s *FilesystemStateSuite) TestSetFilesystemInfoNoFilesystemId(c *gc.C) {
 	_, u, storageTag := s.setupSingleStorage(c, "filesystem", "loop-pool")
 	err := s.State.AssignUnit(u, state.AssignCleanEmpty)
@@ -1712,6 +1712,11 @@ func (e *exporter) addFilesystem(fs *filesystem, fsAttachments []filesystemAttac
 		args.Size = info.Size
 		args.Pool = info.Pool
 		args.FilesystemID = info.FilesystemId
+		// The filesystem's UUID and label are not exported, as the
+		// description package has nowhere to record them. Managed
+		// filesystems are still mounted by UUID after migration:
+		// the source reads the UUID and label from the device when
+		// they are not recorded.
 	} else {
 		params, _ := fs.Params()
 		logger.Debugf("  params %#v", params)
@@ -737,7 +737,12 @@ func (s *MigrationSuite) TestFilesystemDocFields(c *gc.C) {
 	s.AssertExportedFields(c, filesystemDoc{}, migrated.Union(ignored))
 	// The info and params fields ar structs.
 	s.AssertExportedFields(c, FilesystemInfo{}, set.NewStrings(
-		"Size", "Pool", "FilesystemId"))
+		"Size", "Pool", "FilesystemId",
+		// Not migrated, as the description package has nowhere
+		// to record them; the managed filesystem source reads the
+		// UUID and label from the device when they are not recorded.
+		"UUID", "Label",
+	))
 	s.AssertExportedFields(c, FilesystemParams{}, set.NewStrings(
 		"Size", "Pool"))
 }
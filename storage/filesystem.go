@@ -26,6 +26,14 @@ type FilesystemInfo struct {
 
 	// Size is the size of the filesystem, in MiB.
 	Size uint64
+
+	// UUID is the UUID of the filesystem, if known. Unlike the path
+	// of the device it is created on, the UUID does not change when
+	// the machine is rebooted.
+	UUID string
+
+	// Label is the label of the filesystem, if it has one.
+	Label string
 }
 
 // FilesystemAttachment describes machine-specific filesystem attachment information,
@@ -274,6 +274,11 @@ type FilesystemAttachmentParams struct {
 	// Path is the path at which the filesystem is to be mounted on the machine that
 	// this attachment corresponds to.
 	Path string
+
+	// Attributes is the set of provider-specific options of the storage
+	// pool the filesystem was created from. Storage providers may use
+	// these to determine how the filesystem should be attached.
+	Attributes map[string]interface{}
 }
 
 // CreateVolumesResult contains the result of a VolumeSource.CreateVolumes call
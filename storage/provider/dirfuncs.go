@@ -33,6 +33,10 @@ type dirFuncs interface {
 	// mountPointSource returns the source of the mount-point
 	// that contains the specified path.
 	mountPointSource(path string) (string, error)
+
+	// mountPointOptions returns the mount options of the
+	// mount-point at the specified path.
+	mountPointOptions(path string) ([]string, error)
 }
 
 // osDirFuncs is an implementation of dirFuncs that operates on the real
@@ -84,6 +88,14 @@ func (o *osDirFuncs) mountPointSource(path string) (string, error) {
 	return source, err
 }
 
+func (o *osDirFuncs) mountPointOptions(path string) ([]string, error) {
+	output, err := o.run("findmnt", "--noheadings", "--output=OPTIONS", path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return strings.Split(strings.TrimSpace(output), ","), nil
+}
+
 func df(run runCommandFunc, path, field string) (string, error) {
 	output, err := run("df", "--output="+field, path)
 	if err != nil {
@@ -48,6 +48,14 @@ func NewMockManagedFilesystemSource(
 	}, dirFuncs
 }
 
+func IsAttached(source storage.FilesystemSource, tag names.FilesystemTag, path string) (bool, error) {
+	return source.(*managedFilesystemSource).IsAttached(tag, path)
+}
+
+func DiscoverFilesystems(source storage.FilesystemSource) ([]storage.Filesystem, error) {
+	return source.(*managedFilesystemSource).DiscoverFilesystems()
+}
+
 var _ dirFuncs = (*MockDirFuncs)(nil)
 
 // MockDirFuncs stub out the real mkdir and lstat functions from stdlib.
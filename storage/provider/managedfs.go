@@ -6,9 +6,14 @@ package provider
 import (
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/juju/errors"
+	"github.com/juju/utils"
+	"github.com/juju/utils/set"
 	"gopkg.in/juju/names.v2"
 
 	"github.com/juju/juju/storage"
@@ -18,6 +23,78 @@ const (
 	// defaultFilesystemType is the default filesystem type
 	// to create for volume-backed managed filesystems.
 	defaultFilesystemType = "ext4"
+
+	// filesystemTypeAttr is the pool attribute that may be used
+	// to override the type of filesystem to create.
+	filesystemTypeAttr = "filesystem-type"
+
+	// mkfsOptionsAttr is the pool attribute that may be used to
+	// pass additional options to mkfs when creating a filesystem.
+	mkfsOptionsAttr = "mkfs-options"
+
+	// mountOptionsAttr is the pool attribute that may be used to
+	// pass additional options to mount when attaching a filesystem.
+	mountOptionsAttr = "mount-options"
+
+	// allowNonEmptyAttr is the pool attribute that may be used to
+	// permit mounting a filesystem over a non-empty directory,
+	// hiding the directory's contents.
+	allowNonEmptyAttr = "allow-nonempty"
+
+	// mountByUUIDAttr is the pool attribute that may be used to
+	// mount a filesystem by its UUID rather than by device path.
+	mountByUUIDAttr = "mount-by-uuid"
+
+	// labelAttr is the pool attribute that may be used to label
+	// the filesystems created. All filesystems created from the
+	// pool are given the same label, so it should only be used
+	// to mount by label where each machine has one of them.
+	labelAttr = "label"
+
+	// mountByLabelAttr is the pool attribute that may be used to
+	// mount a filesystem by its label rather than by device path.
+	mountByLabelAttr = "mount-by-label"
+
+	// fsckAttr is the pool attribute that may be used to disable
+	// checking filesystems with fsck before mounting them. Checking
+	// is enabled by default for filesystem types that support it.
+	fsckAttr = "fsck"
+
+	// encryptAttr is the pool attribute that may be used to encrypt
+	// filesystems with LUKS. The key must be supplied in the file
+	// named by the encryptKeyFileAttr attribute.
+	encryptAttr = "encrypt"
+
+	// encryptKeyFileAttr is the pool attribute that names the file
+	// on the machine holding the key for encrypted filesystems. The
+	// key is passed to cryptsetup by path, so it never appears in
+	// command lines or logs.
+	encryptKeyFileAttr = "encrypt-key-file"
+
+	// quotaAttr is the pool attribute that may be used to limit
+	// the space that the user named by the quotaUserAttr attribute
+	// may use on a filesystem. The value is a size, e.g. "10G".
+	// Quotas are only supported for ext2, ext3 and ext4.
+	quotaAttr = "quota"
+
+	// quotaUserAttr is the pool attribute that names the user
+	// to whom the quota applies.
+	quotaUserAttr = "quota-user"
+
+	// luksFilesystemType is the type reported by blkid for
+	// devices formatted with LUKS.
+	luksFilesystemType = "crypto_LUKS"
+
+	// shellMetacharacters holds the characters that are not
+	// permitted in mkfs and mount options.
+	shellMetacharacters = "|&;<>()$`\\\"'*?[]{}#~!\n"
+)
+
+// supportedFilesystemTypes holds the filesystem types that may be
+// created for volume-backed managed filesystems. Each of these has
+// a corresponding mkfs.<type> command.
+var supportedFilesystemTypes = set.NewStrings(
+	"ext2", "ext3", "ext4", "xfs", "btrfs",
 )
 
 // managedFilesystemSource is an implementation of storage.FilesystemSource
@@ -82,29 +159,147 @@ func (s *managedFilesystemSource) CreateFilesystems(args []storage.FilesystemPar
 }
 
 func (s *managedFilesystemSource) createFilesystem(arg storage.FilesystemParams) (*storage.Filesystem, error) {
+	fsType, err := filesystemType(arg.Attributes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	mkfsOptions, err := commandOptions(arg.Attributes, mkfsOptionsAttr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := commandOptions(arg.Attributes, mountOptionsAttr); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := boolAttribute(arg.Attributes, allowNonEmptyAttr); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := boolAttribute(arg.Attributes, mountByUUIDAttr); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := boolAttribute(arg.Attributes, mountByLabelAttr); err != nil {
+		return nil, errors.Trace(err)
+	}
+	requestedLabel, err := labelAttribute(arg.Attributes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	label := requestedLabel
+	if err := validateLabel(label, fsType); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := boolAttribute(arg.Attributes, fsckAttr); err != nil {
+		return nil, errors.Trace(err)
+	}
+	encryptKeyFile, err := encryptionKeyFile(arg.Attributes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	quota, err := quotaAttributes(arg.Attributes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if quota != nil && !supportsQuotas(fsType) {
+		return nil, errors.NotSupportedf("quotas on %s filesystems", fsType)
+	}
 	blockDevice, err := s.backingVolumeBlockDevice(arg.Volume)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	devicePath := devicePath(blockDevice)
+	filesystemPath := devicePath
 	if isDiskDevice(devicePath) {
-		if err := destroyPartitions(s.run, devicePath); err != nil {
+		filesystemPath = partitionDevicePath(devicePath)
+	}
+	// If the volume already contains a filesystem, e.g. because it
+	// has been reattached to a replacement machine, then we must not
+	// destroy its contents by repartitioning or reformatting it.
+	existingType := existingFilesystemType(s.run, filesystemPath)
+	if encryptKeyFile != "" {
+		switch existingType {
+		case "":
+			if filesystemPath != devicePath {
+				if err := destroyPartitions(s.run, devicePath); err != nil {
+					return nil, errors.Trace(err)
+				}
+				if err := createPartition(s.run, devicePath); err != nil {
+					return nil, errors.Trace(err)
+				}
+			}
+			if err := formatEncryptedDevice(s.run, filesystemPath, encryptKeyFile); err != nil {
+				return nil, errors.Trace(err)
+			}
+		case luksFilesystemType:
+			logger.Infof("%q is already encrypted", filesystemPath)
+		default:
+			return nil, errors.Errorf(
+				"cannot encrypt %q: it already contains an unencrypted %s filesystem",
+				filesystemPath, existingType,
+			)
+		}
+		// The filesystem is created on, and later mounted
+		// from, the device mapped to the decrypted volume.
+		mapperPath, err := openEncryptedDevice(s.run, filesystemPath, mapperName(arg.Tag), encryptKeyFile)
+		if err != nil {
 			return nil, errors.Trace(err)
 		}
-		if err := createPartition(s.run, devicePath); err != nil {
+		devicePath, filesystemPath = mapperPath, mapperPath
+		existingType = existingFilesystemType(s.run, filesystemPath)
+	}
+	if existingType != "" {
+		if existingType != fsType {
+			logger.Warningf(
+				"%q already contains a %s filesystem, not creating %s filesystem",
+				filesystemPath, existingType, fsType,
+			)
+		} else {
+			logger.Infof("%q already contains a %s filesystem", filesystemPath, existingType)
+		}
+		if label != "" {
+			// Report the existing filesystem's label,
+			// which may differ from the one requested.
+			label = filesystemLabel(s.run, filesystemPath)
+			if label != requestedLabel {
+				logger.Warningf("%q has label %q, not %q", filesystemPath, label, requestedLabel)
+			}
+		}
+	} else {
+		if filesystemPath != devicePath {
+			if err := destroyPartitions(s.run, devicePath); err != nil {
+				return nil, errors.Trace(err)
+			}
+			if err := createPartition(s.run, devicePath); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if err := createFilesystem(s.run, filesystemPath, fsType, label, mkfsOptions); err != nil {
 			return nil, errors.Trace(err)
 		}
-		devicePath = partitionDevicePath(devicePath)
+		if quota != nil {
+			if err := enableQuotas(s.run, filesystemPath); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
 	}
-	if err := createFilesystem(s.run, devicePath); err != nil {
-		return nil, errors.Trace(err)
+	if existingType != "" {
+		fsType = existingType
+	}
+
+	// Report the usable size of the filesystem where possible,
+	// as the filesystem's metadata takes up some of the device.
+	size := blockDevice.Size
+	if fsSize, err := filesystemSize(s.run, filesystemPath, fsType); err != nil {
+		logger.Debugf("using size of %q for filesystem: %v", devicePath, err)
+	} else {
+		size = fsSize
 	}
 	return &storage.Filesystem{
 		arg.Tag,
 		arg.Volume,
 		storage.FilesystemInfo{
 			arg.Tag.String(),
-			blockDevice.Size,
+			size,
+			filesystemUUID(s.run, filesystemPath),
+			label,
 		},
 	}, nil
 }
@@ -144,9 +339,85 @@ func (s *managedFilesystemSource) attachFilesystem(arg storage.FilesystemAttachm
 	if isDiskDevice(devicePath) {
 		devicePath = partitionDevicePath(devicePath)
 	}
-	if err := mountFilesystem(s.run, s.dirFuncs, devicePath, arg.Path, arg.ReadOnly); err != nil {
+	// The pool attributes are supplied with each attachment, so
+	// encrypted filesystems can be opened even if they were not
+	// created by this source, e.g. after the agent restarts.
+	keyFile, err := encryptionKeyFile(arg.Attributes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if keyFile != "" {
+		devicePath, err = openEncryptedDevice(s.run, devicePath, mapperName(arg.Filesystem), keyFile)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	allowNonEmpty, err := boolAttribute(arg.Attributes, allowNonEmptyAttr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	mountOptions, err := commandOptions(arg.Attributes, mountOptionsAttr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	quota, err := quotaAttributes(arg.Attributes)
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	check := true
+	if _, ok := arg.Attributes[fsckAttr]; ok {
+		if check, err = boolAttribute(arg.Attributes, fsckAttr); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	mountByUUID, err := boolAttribute(arg.Attributes, mountByUUIDAttr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	mountByLabel, err := boolAttribute(arg.Attributes, mountByLabelAttr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var mountSource string
+	switch {
+	case mountByUUID:
+		uuid := filesystem.UUID
+		if uuid == "" {
+			// The UUID is not recorded for filesystems
+			// created by older agents, so ask blkid.
+			uuid = filesystemUUID(s.run, devicePath)
+		}
+		if uuid == "" {
+			logger.Warningf("UUID of filesystem %s not known, mounting by device path", arg.Filesystem.Id())
+		} else {
+			mountSource = "UUID=" + uuid
+		}
+	case mountByLabel:
+		label := filesystem.Label
+		if label == "" {
+			// The label is not recorded for filesystems
+			// created by older agents, so ask blkid.
+			label = filesystemLabel(s.run, devicePath)
+		}
+		if label == "" {
+			logger.Warningf("filesystem %s has no label, mounting by device path", arg.Filesystem.Id())
+		} else {
+			mountSource = "LABEL=" + label
+		}
+	}
+	if quota != nil {
+		// Quotas are only enforced on filesystems
+		// mounted with the usrquota option.
+		mountOptions = append(mountOptions, "usrquota")
+	}
+	if err := mountFilesystem(s.run, s.dirFuncs, devicePath, mountSource, arg.Path, arg.ReadOnly, mountOptions, allowNonEmpty, check); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if quota != nil {
+		if err := setQuota(s.run, *quota, arg.Path); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	return &storage.FilesystemAttachment{
 		arg.Filesystem,
 		arg.Machine,
@@ -161,13 +432,130 @@ func (s *managedFilesystemSource) attachFilesystem(arg storage.FilesystemAttachm
 func (s *managedFilesystemSource) DetachFilesystems(args []storage.FilesystemAttachmentParams) ([]error, error) {
 	results := make([]error, len(args))
 	for i, arg := range args {
-		if err := maybeUnmount(s.run, s.dirFuncs, arg.Path); err != nil {
+		if err := s.detachFilesystem(arg); err != nil {
 			results[i] = err
 		}
 	}
 	return results, nil
 }
 
+func (s *managedFilesystemSource) detachFilesystem(arg storage.FilesystemAttachmentParams) error {
+	keyFile, err := encryptionKeyFile(arg.Attributes)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := maybeUnmount(s.run, s.dirFuncs, arg.Path); err != nil {
+		return errors.Trace(err)
+	}
+	if keyFile != "" {
+		// The decrypted device is no longer in use.
+		if err := closeEncryptedDevice(s.run, mapperName(arg.Filesystem)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// IsAttached reports whether the filesystem with the given tag is
+// currently mounted at the given path, by comparing the source of
+// the mount at that path with the filesystem's backing device.
+func (s *managedFilesystemSource) IsAttached(tag names.FilesystemTag, path string) (bool, error) {
+	filesystem, ok := s.filesystems[tag]
+	if !ok {
+		return false, errors.NotFoundf("filesystem %v", tag.Id())
+	}
+	blockDevice, err := s.backingVolumeBlockDevice(filesystem.Volume)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	devicePath := devicePath(blockDevice)
+	if isDiskDevice(devicePath) {
+		devicePath = partitionDevicePath(devicePath)
+	}
+	mounted, mountSource, err := isMounted(s.dirFuncs, path)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if !mounted {
+		return false, nil
+	}
+	// Encrypted filesystems are mounted from the device-mapper
+	// device, whose name is derived from the filesystem's tag.
+	mountSource = filepath.Clean(mountSource)
+	if mountSource != filepath.Clean(devicePath) && mountSource != mapperDevicePath(mapperName(tag)) {
+		logger.Debugf("%q is mounted at %q, expected %q", mountSource, path, devicePath)
+		return false, nil
+	}
+	return true, nil
+}
+
+// DiscoverFilesystems probes the block devices of the volumes backing
+// the source's known filesystems, so that state can be recovered after
+// the agent restarts. Each filesystem found on its backing volume has
+// its information updated in the source's filesystems map, and is
+// returned, ordered by tag. Filesystems whose backing volumes are not
+// attached, or do not contain a filesystem, are left unchanged.
+//
+// Mount state is not recorded; AttachFilesystems may be called again
+// for filesystems that are already mounted.
+func (s *managedFilesystemSource) DiscoverFilesystems() ([]storage.Filesystem, error) {
+	tags := make([]names.FilesystemTag, 0, len(s.filesystems))
+	for tag := range s.filesystems {
+		tags = append(tags, tag)
+	}
+	sort.Sort(byFilesystemTag(tags))
+
+	var discovered []storage.Filesystem
+	for _, tag := range tags {
+		filesystem := s.filesystems[tag]
+		blockDevice, ok := s.volumeBlockDevices[filesystem.Volume]
+		if !ok {
+			logger.Debugf("backing-volume of filesystem %s is not attached", tag.Id())
+			continue
+		}
+		devicePath := devicePath(blockDevice)
+		filesystemPath := devicePath
+		if isDiskDevice(devicePath) {
+			filesystemPath = partitionDevicePath(devicePath)
+		}
+		fsType := existingFilesystemType(s.run, filesystemPath)
+		if fsType == luksFilesystemType {
+			// The key is only known when the filesystem is attached,
+			// so encrypted filesystems can only be probed if they
+			// are already open.
+			name := mapperName(tag)
+			if _, err := s.run("cryptsetup", "status", name); err != nil {
+				logger.Debugf("filesystem %s on %q is encrypted, and not open", tag.Id(), filesystemPath)
+				continue
+			}
+			devicePath, filesystemPath = mapperDevicePath(name), mapperDevicePath(name)
+			fsType = existingFilesystemType(s.run, filesystemPath)
+		}
+		if fsType == "" {
+			logger.Warningf("filesystem %s not found on %q", tag.Id(), filesystemPath)
+			continue
+		}
+		size := blockDevice.Size
+		if fsSize, err := filesystemSize(s.run, filesystemPath, fsType); err != nil {
+			logger.Debugf("using size of %q for filesystem: %v", devicePath, err)
+		} else {
+			size = fsSize
+		}
+		filesystem.FilesystemId = tag.String()
+		filesystem.Size = size
+		filesystem.UUID = filesystemUUID(s.run, filesystemPath)
+		s.filesystems[tag] = filesystem
+		discovered = append(discovered, filesystem)
+	}
+	return discovered, nil
+}
+
+type byFilesystemTag []names.FilesystemTag
+
+func (s byFilesystemTag) Len() int           { return len(s) }
+func (s byFilesystemTag) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFilesystemTag) Less(i, j int) bool { return s[i].String() < s[j].String() }
+
 func destroyPartitions(run runCommandFunc, devicePath string) error {
 	logger.Debugf("destroying partitions on %q", devicePath)
 	if _, err := run("sgdisk", "--zap-all", devicePath); err != nil {
@@ -186,18 +574,326 @@ func createPartition(run runCommandFunc, devicePath string) error {
 	return nil
 }
 
-func createFilesystem(run runCommandFunc, devicePath string) error {
-	logger.Debugf("attempting to create filesystem on %q", devicePath)
-	mkfscmd := "mkfs." + defaultFilesystemType
-	_, err := run(mkfscmd, devicePath)
+// filesystemType returns the type of filesystem to create, as
+// specified by the filesystem-type attribute, or the default
+// filesystem type if none is specified.
+func filesystemType(attrs map[string]interface{}) (string, error) {
+	value, ok := attrs[filesystemTypeAttr]
+	if !ok {
+		return defaultFilesystemType, nil
+	}
+	fsType, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("expected string for %q, got %T", filesystemTypeAttr, value)
+	}
+	if fsType == "" {
+		return defaultFilesystemType, nil
+	}
+	if !supportedFilesystemTypes.Contains(fsType) {
+		return "", errors.NotSupportedf("filesystem type %q", fsType)
+	}
+	return fsType, nil
+}
+
+// existingFilesystemType returns the type of the filesystem on the
+// device with the specified path, or the empty string if the device
+// does not contain a recognised filesystem.
+func existingFilesystemType(run runCommandFunc, devicePath string) string {
+	output, err := run("blkid", "-o", "value", "-s", "TYPE", devicePath)
 	if err != nil {
-		return errors.Annotatef(err, "%s failed", mkfscmd)
+		// blkid exits with a non-zero status if the device
+		// does not exist or has no recognised filesystem.
+		logger.Debugf("no filesystem found on %q: %v", devicePath, err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// filesystemUUID returns the UUID of the filesystem on the device
+// with the specified path, or the empty string if it cannot be
+// determined.
+func filesystemUUID(run runCommandFunc, devicePath string) string {
+	output, err := run("blkid", "-o", "value", "-s", "UUID", devicePath)
+	if err != nil {
+		logger.Debugf("cannot determine UUID of filesystem on %q: %v", devicePath, err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// filesystemLabel returns the label of the filesystem on the device
+// with the specified path, or the empty string if it has none or it
+// cannot be determined.
+func filesystemLabel(run runCommandFunc, devicePath string) string {
+	output, err := run("blkid", "-o", "value", "-s", "LABEL", devicePath)
+	if err != nil {
+		logger.Debugf("cannot determine label of filesystem on %q: %v", devicePath, err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// labelAttribute returns the label to give created filesystems,
+// as specified by the label attribute, or the empty string if
+// none is specified.
+func labelAttribute(attrs map[string]interface{}) (string, error) {
+	value, ok := attrs[labelAttr]
+	if !ok {
+		return "", nil
+	}
+	label, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("expected string for %q, got %T", labelAttr, value)
+	}
+	return label, nil
+}
+
+// maxLabelLengths holds the maximum length of a label
+// for each of the supported filesystem types.
+var maxLabelLengths = map[string]int{
+	"ext2":  16,
+	"ext3":  16,
+	"ext4":  16,
+	"xfs":   12,
+	"btrfs": 255,
+}
+
+// validateLabel returns an error if the given label cannot
+// be given to a filesystem of the specified type.
+func validateLabel(label, fsType string) error {
+	if max := maxLabelLengths[fsType]; len(label) > max {
+		return errors.NotValidf(
+			"label %q (longer than %d characters for %s filesystems)",
+			label, max, fsType,
+		)
+	}
+	return nil
+}
+
+// boolAttribute returns the value of the named boolean attribute,
+// which may be either a bool or a string, or false if it is not set.
+func boolAttribute(attrs map[string]interface{}, name string) (bool, error) {
+	switch value := attrs[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case string:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, errors.NotValidf("%s value %q", name, value)
+		}
+		return b, nil
+	default:
+		return false, errors.NotValidf("%s value %v", name, value)
+	}
+}
+
+// encryptionKeyFile returns the path of the key file to use for
+// encrypting a filesystem, or the empty string if the filesystem is
+// not to be encrypted.
+func encryptionKeyFile(attrs map[string]interface{}) (string, error) {
+	encrypt, err := boolAttribute(attrs, encryptAttr)
+	if err != nil || !encrypt {
+		return "", errors.Trace(err)
+	}
+	keyFile, _ := attrs[encryptKeyFileAttr].(string)
+	if keyFile == "" {
+		return "", errors.Errorf("%q must be specified when %q is true", encryptKeyFileAttr, encryptAttr)
+	}
+	if !filepath.IsAbs(keyFile) {
+		return "", errors.NotValidf("%s %q (not an absolute path)", encryptKeyFileAttr, keyFile)
+	}
+	return keyFile, nil
+}
+
+// userQuota describes a limit on the space a user may
+// use on a filesystem.
+type userQuota struct {
+	user string
+	// size is the limit in MiB.
+	size uint64
+}
+
+// quotaAttributes returns the user quota specified by the quota
+// and quota-user attributes, or nil if no quota is specified.
+func quotaAttributes(attrs map[string]interface{}) (*userQuota, error) {
+	value, ok := attrs[quotaAttr]
+	if !ok {
+		return nil, nil
+	}
+	sizeString, ok := value.(string)
+	if !ok {
+		return nil, errors.Errorf("expected string for %q, got %T", quotaAttr, value)
+	}
+	size, err := utils.ParseSize(sizeString)
+	if err != nil {
+		return nil, errors.Annotatef(err, "parsing %s", quotaAttr)
+	}
+	if size == 0 {
+		return nil, errors.NotValidf("%s %q", quotaAttr, sizeString)
+	}
+	user, _ := attrs[quotaUserAttr].(string)
+	if user == "" {
+		return nil, errors.Errorf("%q must be specified with %q", quotaUserAttr, quotaAttr)
+	}
+	if strings.ContainsAny(user, shellMetacharacters) || strings.HasPrefix(user, "-") {
+		return nil, errors.NotValidf("%s %q", quotaUserAttr, user)
+	}
+	return &userQuota{user, size}, nil
+}
+
+// commandOptions returns the command line options held in the named
+// attribute, which may be either a space-separated string or a list
+// of strings. Options containing shell metacharacters are rejected.
+func commandOptions(attrs map[string]interface{}, name string) ([]string, error) {
+	var options []string
+	switch value := attrs[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		options = strings.Fields(value)
+	case []string:
+		options = value
+	case []interface{}:
+		for _, v := range value {
+			option, ok := v.(string)
+			if !ok {
+				return nil, errors.NotValidf("%s %v", name, value)
+			}
+			options = append(options, option)
+		}
+	default:
+		return nil, errors.NotValidf("%s %v", name, value)
+	}
+	for _, option := range options {
+		if strings.ContainsAny(option, shellMetacharacters) {
+			return nil, errors.NotValidf("%s option %q", name, option)
+		}
+	}
+	return options, nil
+}
+
+func createFilesystem(run runCommandFunc, devicePath, fsType, label string, options []string) error {
+	logger.Debugf("attempting to create %s filesystem on %q", fsType, devicePath)
+	mkfscmd := "mkfs." + fsType
+	var args []string
+	if label != "" {
+		args = append(args, "-L", label)
+	}
+	args = append(append(args, options...), devicePath)
+	// The combined output of a failed command is
+	// included in the error returned by logAndExec.
+	_, err := run(mkfscmd, args...)
+	if err != nil {
+		return errors.Annotatef(err, "%s failed (%q)", mkfscmd, devicePath)
 	}
 	logger.Infof("created filesystem on %q", devicePath)
 	return nil
 }
 
-func mountFilesystem(run runCommandFunc, dirFuncs dirFuncs, devicePath, mountPoint string, readOnly bool) error {
+// mapperName returns the name of the device-mapper device
+// used to access the decrypted contents of a filesystem.
+func mapperName(tag names.FilesystemTag) string {
+	return "juju-" + tag.String()
+}
+
+// mapperDevicePath returns the path of the device-mapper
+// device with the given name.
+func mapperDevicePath(name string) string {
+	return path.Join("/dev/mapper", name)
+}
+
+// formatEncryptedDevice initialises LUKS encryption on the device with
+// the specified path, using the key held in keyFile. Any existing data
+// on the device is lost.
+func formatEncryptedDevice(run runCommandFunc, devicePath, keyFile string) error {
+	logger.Debugf("encrypting %q", devicePath)
+	if _, err := run("cryptsetup", "luksFormat", "--batch-mode", "--key-file", keyFile, devicePath); err != nil {
+		return errors.Annotatef(err, "cryptsetup luksFormat failed (%q)", devicePath)
+	}
+	logger.Infof("encrypted %q", devicePath)
+	return nil
+}
+
+// openEncryptedDevice opens the LUKS-encrypted device with the specified
+// path as the named device-mapper device, if it is not already open, and
+// returns the path of the mapped device.
+func openEncryptedDevice(run runCommandFunc, devicePath, name, keyFile string) (string, error) {
+	mapperPath := mapperDevicePath(name)
+	if _, err := run("cryptsetup", "status", name); err == nil {
+		logger.Debugf("%q already opened as %q", devicePath, mapperPath)
+		return mapperPath, nil
+	}
+	if _, err := run("cryptsetup", "luksOpen", "--key-file", keyFile, devicePath, name); err != nil {
+		return "", errors.Annotatef(err, "cryptsetup luksOpen failed (%q)", devicePath)
+	}
+	logger.Infof("opened %q as %q", devicePath, mapperPath)
+	return mapperPath, nil
+}
+
+// closeEncryptedDevice closes the named device-mapper device, if it
+// is open.
+func closeEncryptedDevice(run runCommandFunc, name string) error {
+	if _, err := run("cryptsetup", "status", name); err != nil {
+		return nil
+	}
+	if _, err := run("cryptsetup", "luksClose", name); err != nil {
+		return errors.Annotatef(err, "cryptsetup luksClose failed (%q)", name)
+	}
+	logger.Infof("closed %q", mapperDevicePath(name))
+	return nil
+}
+
+// filesystemSize returns the size, in MiB, of the filesystem on the
+// device with the specified path. This excludes the space taken up
+// by the filesystem's own metadata. Only ext2, ext3 and ext4
+// filesystems are supported.
+func filesystemSize(run runCommandFunc, devicePath, fsType string) (uint64, error) {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+	default:
+		return 0, errors.NotSupportedf("determining the size of a %s filesystem", fsType)
+	}
+	output, err := run("dumpe2fs", "-h", devicePath)
+	if err != nil {
+		return 0, errors.Annotate(err, "dumpe2fs failed")
+	}
+	fields := make(map[string]uint64)
+	for _, line := range strings.Split(output, "\n") {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(line[colon+1:]), 10, 64)
+		if err != nil {
+			continue
+		}
+		fields[strings.TrimSpace(line[:colon])] = value
+	}
+	blockCount := fields["Block count"]
+	blockSize := fields["Block size"]
+	overhead := fields["Overhead clusters"]
+	if blockCount == 0 || blockSize == 0 || overhead >= blockCount {
+		return 0, errors.Errorf("cannot determine size of filesystem on %q", devicePath)
+	}
+	return (blockCount - overhead) * blockSize / (1024 * 1024), nil
+}
+
+// mountFilesystem mounts the filesystem on the device with the given
+// path at the mount point. If source is non-empty, it is given to mount
+// in place of the device path, e.g. to identify the filesystem by UUID
+// ("UUID=...") or label ("LABEL=..."). If check is true, and fsck can
+// check the filesystem's type, the filesystem is checked first. The type
+// is probed, as it may differ from the one requested for the pool.
+func mountFilesystem(
+	run runCommandFunc,
+	dirFuncs dirFuncs,
+	devicePath, source, mountPoint string,
+	readOnly bool,
+	options []string,
+	allowNonEmpty, check bool,
+) error {
 	logger.Debugf("attempting to mount filesystem on %q at %q", devicePath, mountPoint)
 	if err := dirFuncs.mkDirAll(mountPoint, 0755); err != nil {
 		return errors.Annotate(err, "creating mount point")
@@ -207,14 +903,39 @@ func mountFilesystem(run runCommandFunc, dirFuncs dirFuncs, devicePath, mountPoi
 		return errors.Trace(err)
 	}
 	if mounted {
+		if filepath.Clean(mountSource) != filepath.Clean(devicePath) {
+			return errors.Errorf(
+				"cannot mount %q at %q: %q is already mounted there",
+				devicePath, mountPoint, mountSource,
+			)
+		}
 		logger.Debugf("filesystem on %q already mounted at %q", mountSource, mountPoint)
-		return nil
+		return remountIfModeChanged(run, dirFuncs, mountPoint, readOnly)
 	}
-	var args []string
+	if err := checkMountPointEmpty(dirFuncs, mountPoint, allowNonEmpty); err != nil {
+		return errors.Trace(err)
+	}
+	if check {
+		if fsType := existingFilesystemType(run, devicePath); supportsFsck(fsType) {
+			if err := checkFilesystem(run, devicePath); err != nil {
+				return errors.Trace(err)
+			}
+		} else {
+			logger.Debugf("not checking %q filesystem on %q", fsType, devicePath)
+		}
+	}
+	options = append([]string{}, options...)
 	if readOnly {
-		args = append(args, "-o", "ro")
+		options = append(options, "ro")
+	}
+	var args []string
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	if source == "" {
+		source = devicePath
 	}
-	args = append(args, devicePath, mountPoint)
+	args = append(args, source, mountPoint)
 	if _, err := run("mount", args...); err != nil {
 		return errors.Annotate(err, "mount failed")
 	}
@@ -222,6 +943,129 @@ func mountFilesystem(run runCommandFunc, dirFuncs dirFuncs, devicePath, mountPoi
 	return nil
 }
 
+// checkMountPointEmpty returns an error if the directory at the given
+// mount point is not empty, as its contents would be hidden by the
+// mount, unless allowNonEmpty is true.
+func checkMountPointEmpty(dirFuncs dirFuncs, mountPoint string, allowNonEmpty bool) error {
+	count, err := dirFuncs.fileCount(mountPoint)
+	if err != nil {
+		return errors.Annotate(err, "checking mount point")
+	}
+	if count == 0 {
+		return nil
+	}
+	if !allowNonEmpty {
+		return errors.Errorf(
+			"cannot mount at %q: directory is not empty (set %q to allow)",
+			mountPoint, allowNonEmptyAttr,
+		)
+	}
+	logger.Warningf("mounting over non-empty directory %q, hiding its contents", mountPoint)
+	return nil
+}
+
+// supportsFsck reports whether filesystems of the given type can be
+// checked and repaired by "fsck -p". The fsck helpers for xfs and
+// btrfs do nothing, so there is no point running them.
+func supportsFsck(fsType string) bool {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		return true
+	}
+	return false
+}
+
+// checkFilesystem checks the filesystem on the device with the given
+// path, automatically repairing any problems that can be safely fixed.
+// An error is returned if the filesystem has errors that require
+// manual intervention, or if it could not be checked.
+func checkFilesystem(run runCommandFunc, devicePath string) error {
+	logger.Debugf("checking filesystem on %q", devicePath)
+	_, err := run("fsck", "-p", devicePath)
+	if err == nil {
+		return nil
+	}
+	// The exit code of fsck is a bitmask; 1 and 2 indicate that
+	// errors were found and corrected, and 4 that errors were left
+	// uncorrected.
+	code, ok := exitCode(err)
+	switch {
+	case !ok:
+		return errors.Annotate(err, "fsck failed")
+	case code&^3 == 0:
+		logger.Infof("corrected errors in filesystem on %q", devicePath)
+		return nil
+	case code&4 != 0:
+		return errors.Errorf(
+			"filesystem on %q has errors that fsck cannot repair automatically; manual intervention is required",
+			devicePath,
+		)
+	}
+	return errors.Annotate(err, "fsck failed")
+}
+
+// supportsQuotas reports whether user quotas can be enabled
+// on filesystems of the given type with "tune2fs -O quota".
+func supportsQuotas(fsType string) bool {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		return true
+	}
+	return false
+}
+
+// enableQuotas enables quota accounting on the unmounted
+// filesystem on the device with the given path.
+func enableQuotas(run runCommandFunc, devicePath string) error {
+	logger.Debugf("enabling quotas on %q", devicePath)
+	if _, err := run("tune2fs", "-O", "quota", devicePath); err != nil {
+		return errors.Annotate(err, "tune2fs failed")
+	}
+	return nil
+}
+
+// setQuota limits the space the user may use on the filesystem
+// mounted at the given mount point. The soft and hard block
+// limits are both set to the quota size; inodes are not limited.
+func setQuota(run runCommandFunc, quota userQuota, mountPoint string) error {
+	// setquota takes block limits in units of 1KiB.
+	limit := strconv.FormatUint(quota.size*1024, 10)
+	if _, err := run("setquota", "-u", quota.user, limit, limit, "0", "0", mountPoint); err != nil {
+		return errors.Annotate(err, "setquota failed")
+	}
+	logger.Infof("set quota of %dMiB for %q on %q", quota.size, quota.user, mountPoint)
+	return nil
+}
+
+// remountIfModeChanged remounts the filesystem mounted at the given mount
+// point if its read-only state does not match the one requested.
+func remountIfModeChanged(run runCommandFunc, dirFuncs dirFuncs, mountPoint string, readOnly bool) error {
+	options, err := dirFuncs.mountPointOptions(mountPoint)
+	if err != nil {
+		return errors.Annotate(err, "getting mount options")
+	}
+	mountedReadOnly := false
+	for _, option := range options {
+		if option == "ro" {
+			mountedReadOnly = true
+			break
+		}
+	}
+	if mountedReadOnly == readOnly {
+		return nil
+	}
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	logger.Debugf("remounting filesystem at %q (%s)", mountPoint, mode)
+	if _, err := run("mount", "-o", "remount,"+mode, mountPoint); err != nil {
+		return errors.Annotate(err, "remount failed")
+	}
+	logger.Infof("remounted filesystem at %q (%s)", mountPoint, mode)
+	return nil
+}
+
 func maybeUnmount(run runCommandFunc, dirFuncs dirFuncs, mountPoint string) error {
 	mounted, _, err := isMounted(dirFuncs, mountPoint)
 	if err != nil {
@@ -4,8 +4,12 @@
 package provider_test
 
 import (
+	"fmt"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 	"gopkg.in/juju/names.v2"
@@ -17,6 +21,17 @@ import (
 
 var _ = gc.Suite(&managedfsSuite{})
 
+// dumpe2fsOutput is the output of "dumpe2fs -h" for a 1GiB ext4
+// filesystem, with (262144 - 8805) usable blocks of 4KiB.
+const dumpe2fsOutput = `
+Filesystem volume name:   <none>
+Block count:              262144
+Reserved block count:     13107
+Overhead clusters:        8805
+Free blocks:              253325
+Block size:               4096
+`[1:]
+
 type managedfsSuite struct {
 	testing.BaseSuite
 	commands     *mockRunCommand
@@ -53,12 +68,18 @@ func (s *managedfsSuite) TestCreateFilesystems(c *gc.C) {
 	source := s.initSource(c)
 	// sda is (re)partitioned and the filesystem created
 	// on the partition.
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
 	s.commands.expect("sgdisk", "--zap-all", "/dev/sda")
 	s.commands.expect("sgdisk", "-n", "1:0:-1", "/dev/sda")
 	s.commands.expect("mkfs.ext4", "/dev/sda1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/sda1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/sda1")
 	// xvdf1 is assumed to not require a partition, on
 	// account of ending with a digit.
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
 	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
 
 	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
 		DeviceName: "sda",
@@ -101,79 +122,1426 @@ func (s *managedfsSuite) TestCreateFilesystems(c *gc.C) {
 	}})
 }
 
-func (s *managedfsSuite) TestCreateFilesystemsNoBlockDevice(c *gc.C) {
+func (s *managedfsSuite) TestCreateFilesystemsFilesystemType(c *gc.C) {
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.xfs", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"filesystem-type": "xfs"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, jc.DeepEquals, []storage.CreateFilesystemsResult{{
+		Filesystem: &storage.Filesystem{
+			names.NewFilesystemTag("0/0"),
+			names.NewVolumeTag("0"),
+			storage.FilesystemInfo{
+				FilesystemId: "filesystem-0-0",
+				Size:         3,
+			},
+		},
+	}})
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsFilesystemTypeUnsupported(c *gc.C) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"filesystem-type": "bogus"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `filesystem type "bogus" not supported`)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsMkfsFails(c *gc.C) {
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd := s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	cmd.respond("", errors.New("no space left on device"))
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   3,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `mkfs.ext4 failed \("/dev/xvdf1"\): no space left on device`)
+}
+
+func (s *managedfsSuite) TestDiscoverFilesystems(c *gc.C) {
+	source := s.initSource(c)
+	// 0/0 is on the partition of sda, and 0/1 is on xvdf1. The
+	// volume backing 0/2 does not contain a filesystem, and the
+	// volume backing 0/3 is not attached, so they are skipped.
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
+	cmd.respond("ext4\n", nil)
+	cmd = s.commands.expect("dumpe2fs", "-h", "/dev/sda1")
+	cmd.respond(dumpe2fsOutput, nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/sda1")
+	cmd.respond("0b56138b-6124-4ec4-a7a3-7c503516a65c\n", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("xfs\n", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdg1")
+	cmd.respond("", errors.New("exit status 2"))
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{DeviceName: "sda", Size: 1024}
+	s.blockDevices[names.NewVolumeTag("1")] = storage.BlockDevice{DeviceName: "xvdf1", Size: 3}
+	s.blockDevices[names.NewVolumeTag("2")] = storage.BlockDevice{DeviceName: "xvdg", Size: 4}
+	for i := 0; i < 4; i++ {
+		tag := names.NewFilesystemTag(fmt.Sprintf("0/%d", i))
+		s.filesystems[tag] = storage.Filesystem{
+			Tag:    tag,
+			Volume: names.NewVolumeTag(fmt.Sprint(i)),
+		}
+	}
+
+	filesystems, err := provider.DiscoverFilesystems(source)
+	c.Assert(err, jc.ErrorIsNil)
+	expect := []storage.Filesystem{{
+		names.NewFilesystemTag("0/0"),
+		names.NewVolumeTag("0"),
+		storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         989,
+			UUID:         "0b56138b-6124-4ec4-a7a3-7c503516a65c",
+		},
+	}, {
+		names.NewFilesystemTag("0/1"),
+		names.NewVolumeTag("1"),
+		storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-1",
+			Size:         3,
+		},
+	}}
+	c.Assert(filesystems, jc.DeepEquals, expect)
+	c.Assert(s.filesystems[names.NewFilesystemTag("0/0")], jc.DeepEquals, expect[0])
+	c.Assert(s.filesystems[names.NewFilesystemTag("0/1")], jc.DeepEquals, expect[1])
+	c.Assert(s.filesystems[names.NewFilesystemTag("0/2")].Size, gc.Equals, uint64(0))
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsExistingFilesystem(c *gc.C) {
 	source := s.initSource(c)
+	// Neither sda nor xvdf1 is repartitioned or formatted,
+	// as they already contain filesystems.
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("dumpe2fs", "-h", "/dev/sda1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/sda1")
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("xfs\n", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.blockDevices[names.NewVolumeTag("1")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
 	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
 		Tag:    names.NewFilesystemTag("0/0"),
 		Volume: names.NewVolumeTag("0"),
 		Size:   2,
+	}, {
+		Tag:    names.NewFilesystemTag("0/1"),
+		Volume: names.NewVolumeTag("1"),
+		Size:   3,
 	}})
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(results[0].Error, gc.ErrorMatches, "backing-volume 0 is not yet attached")
+	c.Assert(results, jc.DeepEquals, []storage.CreateFilesystemsResult{{
+		Filesystem: &storage.Filesystem{
+			names.NewFilesystemTag("0/0"),
+			names.NewVolumeTag("0"),
+			storage.FilesystemInfo{
+				FilesystemId: "filesystem-0-0",
+				Size:         2,
+			},
+		},
+	}, {
+		Filesystem: &storage.Filesystem{
+			names.NewFilesystemTag("0/1"),
+			names.NewVolumeTag("1"),
+			storage.FilesystemInfo{
+				FilesystemId: "filesystem-0-1",
+				Size:         3,
+			},
+		},
+	}})
 }
 
-func (s *managedfsSuite) TestAttachFilesystems(c *gc.C) {
-	s.testAttachFilesystems(c, false, false)
+func (s *managedfsSuite) TestCreateFilesystemsReportsFilesystemSize(c *gc.C) {
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	cmd := s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	cmd.respond(dumpe2fsOutput, nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       1024,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   1024,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	// (262144 - 8805) blocks of 4KiB is just under 990MiB.
+	c.Assert(results[0].Filesystem.Size, gc.Equals, uint64(989))
 }
 
-func (s *managedfsSuite) TestAttachFilesystemsReadOnly(c *gc.C) {
-	s.testAttachFilesystems(c, true, false)
+func (s *managedfsSuite) TestCreateFilesystemsMkfsOptions(c *gc.C) {
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "-m", "0", "-E", "lazy_itable_init=0", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"mkfs-options": "-m 0 -E lazy_itable_init=0"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
 }
 
-func (s *managedfsSuite) TestAttachFilesystemsReattach(c *gc.C) {
-	s.testAttachFilesystems(c, true, true)
+func (s *managedfsSuite) TestCreateFilesystemsMkfsOptionsInvalid(c *gc.C) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"mkfs-options": "-m 0; reboot"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `mkfs-options option "0;" not valid`)
 }
 
-func (s *managedfsSuite) testAttachFilesystems(c *gc.C, readOnly, reattach bool) {
+func (s *managedfsSuite) TestAttachFilesystemsMountOptions(c *gc.C) {
 	const testMountPoint = "/in/the/place"
 
 	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
+	s.commands.expect("sgdisk", "--zap-all", "/dev/sda")
+	s.commands.expect("sgdisk", "-n", "1:0:-1", "/dev/sda")
+	s.commands.expect("mkfs.ext4", "/dev/sda1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/sda1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/sda1")
 	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
 	cmd.respond("headers\n/same/as/rootfs", nil)
 	cmd = s.commands.expect("df", "--output=source", testMountPoint)
-	if reattach {
-		cmd.respond("headers\n/different/to/rootfs", nil)
-	} else {
-		cmd.respond("headers\n/same/as/rootfs", nil)
-		var args []string
-		if readOnly {
-			args = append(args, "-o", "ro")
-		}
-		args = append(args, "/dev/sda1", testMountPoint)
-		s.commands.expect("mount", args...)
-	}
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/sda1")
+	s.commands.expect("mount", "-o", "noatime,nodiratime,ro", "/dev/sda1", testMountPoint)
 
 	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
 		DeviceName: "sda",
 		HardwareId: "capncrunch",
 		Size:       2,
 	}
+	attrs := map[string]interface{}{
+		"mount-options": []interface{}{"noatime", "nodiratime"},
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       2,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+			ReadOnly:   true,
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountOptionsAfterRestart(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	// The filesystem was created by a previous incarnation of the
+	// source, so the mount options are only known from the pool
+	// attributes.
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       2,
+	}
 	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
 		Tag:    names.NewFilesystemTag("0/0"),
 		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         2,
+		},
 	}
 
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mount", "-o", "noatime,nodiratime", "/dev/xvdf1", testMountPoint)
+
 	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
 		Filesystem:   names.NewFilesystemTag("0/0"),
 		FilesystemId: "filesystem-0-0",
 		AttachmentParams: storage.AttachmentParams{
 			Machine:    names.NewMachineTag("0"),
 			InstanceId: "inst-ance",
-			ReadOnly:   readOnly,
 		},
 		Path: testMountPoint,
+		Attributes: map[string]interface{}{
+			"mount-options": "noatime nodiratime",
+		},
 	}})
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(results, jc.DeepEquals, []storage.AttachFilesystemsResult{{
-		FilesystemAttachment: &storage.FilesystemAttachment{
-			names.NewFilesystemTag("0/0"),
-			names.NewMachineTag("0"),
-			storage.FilesystemAttachmentInfo{
-				Path:     testMountPoint,
-				ReadOnly: readOnly,
-			},
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountByUUID(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+	const uuid = "0b56138b-6124-4ec4-a7a3-7c503516a65c"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd.respond(uuid+"\n", nil)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "UUID="+uuid, testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"mount-by-uuid": true},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	c.Assert(results[0].Filesystem.UUID, gc.Equals, uuid)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: map[string]interface{}{"mount-by-uuid": true},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountByUUIDNotRecorded(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+	const uuid = "0b56138b-6124-4ec4-a7a3-7c503516a65c"
+
+	// The filesystem's UUID was not recorded when it was
+	// created, so it is read from the device.
+	source := s.initSource(c)
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd.respond(uuid+"\n", nil)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mount", "UUID="+uuid, testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         3,
+		},
+	}
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
 		},
+		Path:       testMountPoint,
+		Attributes: map[string]interface{}{"mount-by-uuid": true},
 	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountByLabel(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "-L", "juju-data", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "LABEL=juju-data", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	attrs := map[string]interface{}{
+		"label":          "juju-data",
+		"mount-by-label": true,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	c.Assert(results[0].Filesystem.Label, gc.Equals, "juju-data")
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountByLabelNotRecorded(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	// The filesystem's label was not recorded when it
+	// was created, so it is read from the device.
+	source := s.initSource(c)
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "LABEL", "/dev/xvdf1")
+	cmd.respond("juju-data\n", nil)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mount", "LABEL=juju-data", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         3,
+		},
+	}
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+		Attributes: map[string]interface{}{
+			"label":          "juju-data",
+			"mount-by-label": true,
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsExistingFilesystemLabel(c *gc.C) {
+	source := s.initSource(c)
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "LABEL", "/dev/xvdf1")
+	cmd.respond("old-data\n", nil)
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"label": "juju-data"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	c.Assert(results[0].Filesystem.Label, gc.Equals, "old-data")
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsLabelTooLong(c *gc.C) {
+	s.testCreateFilesystemsLabelTooLong(c, "ext4", "seventeen-chars!!", 16)
+	s.testCreateFilesystemsLabelTooLong(c, "xfs", "thirteen-char", 12)
+}
+
+func (s *managedfsSuite) testCreateFilesystemsLabelTooLong(c *gc.C, fsType, label string, max int) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   3,
+		Attributes: map[string]interface{}{
+			"filesystem-type": fsType,
+			"label":           label,
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, fmt.Sprintf(
+		`label %q \(longer than %d characters for %s filesystems\) not valid`, label, max, fsType,
+	))
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckClean(c *gc.C) {
+	s.testAttachFilesystemsFsck(c, nil, "")
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckCorrected(c *gc.C) {
+	s.testAttachFilesystemsFsck(c, exitError(c, 1), "")
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckUncorrected(c *gc.C) {
+	s.testAttachFilesystemsFsck(c, exitError(c, 4),
+		`filesystem on "/dev/xvdf1" has errors that fsck cannot repair automatically; manual intervention is required`,
+	)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckOperationalError(c *gc.C) {
+	s.testAttachFilesystemsFsck(c, exitError(c, 8), `fsck failed: exit status 8`)
+}
+
+func (s *managedfsSuite) testAttachFilesystemsFsck(c *gc.C, fsckErr error, expectErr string) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	cmd = s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	cmd.respond("", fsckErr)
+	if expectErr == "" {
+		s.commands.expect("mount", "/dev/xvdf1", testMountPoint)
+	}
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   3,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	if expectErr == "" {
+		c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+	} else {
+		c.Assert(attachResults[0].Error, gc.ErrorMatches, expectErr)
+	}
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckDisabled(c *gc.C) {
+	s.testAttachFilesystemsNoFsck(c, "ext4", map[string]interface{}{"fsck": false})
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckUnsupported(c *gc.C) {
+	s.testAttachFilesystemsNoFsck(c, "xfs", map[string]interface{}{"filesystem-type": "xfs"})
+}
+
+func (s *managedfsSuite) testAttachFilesystemsNoFsck(c *gc.C, fsType string, attrs map[string]interface{}) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs."+fsType, "/dev/xvdf1")
+	if fsType == "ext4" {
+		s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	}
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	if attrs["fsck"] != false {
+		cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+		cmd.respond(fsType+"\n", nil)
+	}
+	s.commands.expect("mount", "/dev/xvdf1", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsFsckExistingFilesystemType(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	// The pool asks for xfs, but the volume already contained an ext4
+	// filesystem when it was provisioned by a previous incarnation of
+	// the source; the filesystem's actual type decides whether to check.
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         3,
+		},
+	}
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "/dev/xvdf1", testMountPoint)
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: map[string]interface{}{"filesystem-type": "xfs"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+}
+
+// exitError returns the error reported by exec for a
+// command that exits with the given code.
+func exitError(c *gc.C, code int) error {
+	if runtime.GOOS == "windows" {
+		c.Skip("exit codes are only simulated on *nix systems")
+	}
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	c.Assert(err, gc.FitsTypeOf, &exec.ExitError{})
+	return err
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsNoBlockDevice(c *gc.C) {
+	source := s.initSource(c)
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   2,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, "backing-volume 0 is not yet attached")
+}
+
+func (s *managedfsSuite) TestAttachFilesystems(c *gc.C) {
+	s.testAttachFilesystems(c, false, false)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsReadOnly(c *gc.C) {
+	s.testAttachFilesystems(c, true, false)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsReattach(c *gc.C) {
+	s.testAttachFilesystems(c, true, true)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsReattachRemount(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/dev/sda1", nil)
+	cmd = s.commands.expect("findmnt", "--noheadings", "--output=OPTIONS", testMountPoint)
+	cmd.respond("ro,relatime\n", nil)
+	s.commands.expect("mount", "-o", "remount,rw", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+	}
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	c.Assert(results[0].FilesystemAttachment.ReadOnly, jc.IsFalse)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsMountConflict(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/dev/sdb1", nil)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+	}
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches,
+		`cannot mount "/dev/sda1" at "/in/the/place": "/dev/sdb1" is already mounted there`)
+}
+
+func (s *managedfsSuite) testAttachFilesystems(c *gc.C, readOnly, reattach bool) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	if reattach {
+		cmd.respond("headers\n/dev/sda1", nil)
+		cmd = s.commands.expect("findmnt", "--noheadings", "--output=OPTIONS", testMountPoint)
+		if readOnly {
+			cmd.respond("ro,relatime\n", nil)
+		} else {
+			cmd.respond("rw,relatime\n", nil)
+		}
+	} else {
+		cmd.respond("headers\n/same/as/rootfs", nil)
+		var args []string
+		if readOnly {
+			args = append(args, "-o", "ro")
+		}
+		args = append(args, "/dev/sda1", testMountPoint)
+		s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/sda1")
+		s.commands.expect("mount", args...)
+	}
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+	}
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+			ReadOnly:   readOnly,
+		},
+		Path: testMountPoint,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, jc.DeepEquals, []storage.AttachFilesystemsResult{{
+		FilesystemAttachment: &storage.FilesystemAttachment{
+			names.NewFilesystemTag("0/0"),
+			names.NewMachineTag("0"),
+			storage.FilesystemAttachmentInfo{
+				Path:     testMountPoint,
+				ReadOnly: readOnly,
+			},
+		},
+	}})
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsNonEmptyMountPoint(c *gc.C) {
+	// MockDirFuncs reports that directories ending in "666" are non-empty.
+	const testMountPoint = "/in/the/666"
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+	}
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `cannot mount at "/in/the/666": directory is not empty \(set "allow-nonempty" to allow\)`)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsAllowNonEmptyMountPoint(c *gc.C) {
+	const testMountPoint = "/in/the/666"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "/dev/xvdf1", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	attrs := map[string]interface{}{"allow-nonempty": "true"}
+	createResults, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(createResults[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *createResults[0].Filesystem
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestAttachFilesystemsAllowNonEmptyMountPointAfterRestart(c *gc.C) {
+	const testMountPoint = "/in/the/666"
+
+	// The filesystem was created by a previous incarnation of the
+	// source, so allow-nonempty is only known from the pool attributes.
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         3,
+		},
+	}
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mount", "/dev/xvdf1", testMountPoint)
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: map[string]interface{}{"allow-nonempty": true},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsAllowNonEmptyInvalid(c *gc.C) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: map[string]interface{}{"allow-nonempty": "perhaps"},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `allow-nonempty value "perhaps" not valid`)
+}
+
+func (s *managedfsSuite) TestEncryptedFilesystem(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+	const keyFile = "/etc/juju/fs.key"
+	const mapperName = "juju-filesystem-0-0"
+	const mapperPath = "/dev/mapper/" + mapperName
+
+	source := s.initSource(c)
+	// The volume is encrypted, opened, and the
+	// filesystem created on the decrypted device.
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("cryptsetup", "luksFormat", "--batch-mode", "--key-file", keyFile, "/dev/xvdf1")
+	cmd := s.commands.expect("cryptsetup", "status", mapperName)
+	cmd.respond("", errors.New("inactive"))
+	s.commands.expect("cryptsetup", "luksOpen", "--key-file", keyFile, "/dev/xvdf1", mapperName)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", mapperPath)
+	s.commands.expect("mkfs.ext4", mapperPath)
+	s.commands.expect("dumpe2fs", "-h", mapperPath)
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", mapperPath)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	attrs := map[string]interface{}{
+		"encrypt":          true,
+		"encrypt-key-file": keyFile,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       3,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+	s.commands.assertDrained()
+
+	// The decrypted device is already open, and is mounted.
+	s.commands.expect("cryptsetup", "status", mapperName)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", mapperPath)
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", mapperPath)
+	s.commands.expect("mount", mapperPath, testMountPoint)
+
+	params := []storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}}
+	attachResults, err := source.AttachFilesystems(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+	s.commands.assertDrained()
+
+	// The decrypted device is closed once unmounted.
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n"+mapperPath, nil)
+	s.commands.expect("umount", testMountPoint)
+	s.commands.expect("cryptsetup", "status", mapperName)
+	s.commands.expect("cryptsetup", "luksClose", mapperName)
+
+	detachResults, err := source.DetachFilesystems(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(detachResults[0], jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestEncryptedFilesystemAfterRestart(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+	const keyFile = "/etc/juju/fs.key"
+	const mapperName = "juju-filesystem-0-0"
+	const mapperPath = "/dev/mapper/" + mapperName
+
+	// The filesystem was created by a previous incarnation of the
+	// source, so the new source only learns of it from the worker.
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         3,
+		},
+	}
+	params := []storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+		Attributes: map[string]interface{}{
+			"encrypt":          true,
+			"encrypt-key-file": keyFile,
+		},
+	}}
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("cryptsetup", "status", mapperName)
+	cmd.respond("", errors.New("inactive"))
+	s.commands.expect("cryptsetup", "luksOpen", "--key-file", keyFile, "/dev/xvdf1", mapperName)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", mapperPath)
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", mapperPath)
+	s.commands.expect("mount", mapperPath, testMountPoint)
+
+	attachResults, err := source.AttachFilesystems(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+	s.commands.assertDrained()
+
+	// The agent restarts again, and finds the
+	// filesystem attached before detaching it.
+	source = s.initSource(c)
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n"+mapperPath, nil)
+	attached, err := provider.IsAttached(source, names.NewFilesystemTag("0/0"), testMountPoint)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attached, jc.IsTrue)
+
+	cmd = s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n"+mapperPath, nil)
+	s.commands.expect("umount", testMountPoint)
+	s.commands.expect("cryptsetup", "status", mapperName)
+	s.commands.expect("cryptsetup", "luksClose", mapperName)
+
+	detachResults, err := source.DetachFilesystems(params)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(detachResults[0], jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsEncryptUnencryptedFilesystem(c *gc.C) {
+	source := s.initSource(c)
+	cmd := s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   3,
+		Attributes: map[string]interface{}{
+			"encrypt":          true,
+			"encrypt-key-file": "/etc/juju/fs.key",
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, `cannot encrypt "/dev/xvdf1": it already contains an unencrypted ext4 filesystem`)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsEncryptKeyFileInvalid(c *gc.C) {
+	s.testCreateFilesystemsEncryptKeyFileInvalid(c, "", `"encrypt-key-file" must be specified when "encrypt" is true`)
+	s.testCreateFilesystemsEncryptKeyFileInvalid(c, "fs.key", `encrypt-key-file "fs.key" \(not an absolute path\) not valid`)
+}
+
+func (s *managedfsSuite) testCreateFilesystemsEncryptKeyFileInvalid(c *gc.C, keyFile, expect string) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       3,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		Size:   3,
+		Attributes: map[string]interface{}{
+			"encrypt":          "true",
+			"encrypt-key-file": keyFile,
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, expect)
+}
+
+func (s *managedfsSuite) TestFilesystemQuota(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	source := s.initSource(c)
+	s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	s.commands.expect("mkfs.ext4", "/dev/xvdf1")
+	s.commands.expect("tune2fs", "-O", "quota", "/dev/xvdf1")
+	s.commands.expect("dumpe2fs", "-h", "/dev/xvdf1")
+	s.commands.expect("blkid", "-o", "value", "-s", "UUID", "/dev/xvdf1")
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "-o", "usrquota", "/dev/xvdf1", testMountPoint)
+	s.commands.expect("setquota", "-u", "alice", "1048576", "1048576", "0", "0", testMountPoint)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       2048,
+	}
+	attrs := map[string]interface{}{
+		"quota":      "1G",
+		"quota-user": "alice",
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       2048,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+	s.filesystems[names.NewFilesystemTag("0/0")] = *results[0].Filesystem
+
+	attachResults, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path:       testMountPoint,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attachResults[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestFilesystemQuotaAfterRestart(c *gc.C) {
+	const testMountPoint = "/in/the/place"
+
+	// The filesystem was created by a previous incarnation of the
+	// source, so the quota is only known from the pool attributes.
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       2048,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+		FilesystemInfo: storage.FilesystemInfo{
+			FilesystemId: "filesystem-0-0",
+			Size:         2048,
+		},
+	}
+
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", filepath.Dir(testMountPoint))
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", testMountPoint)
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("blkid", "-o", "value", "-s", "TYPE", "/dev/xvdf1")
+	cmd.respond("ext4\n", nil)
+	s.commands.expect("fsck", "-p", "/dev/xvdf1")
+	s.commands.expect("mount", "-o", "usrquota", "/dev/xvdf1", testMountPoint)
+	s.commands.expect("setquota", "-u", "alice", "1048576", "1048576", "0", "0", testMountPoint)
+
+	results, err := source.AttachFilesystems([]storage.FilesystemAttachmentParams{{
+		Filesystem:   names.NewFilesystemTag("0/0"),
+		FilesystemId: "filesystem-0-0",
+		AttachmentParams: storage.AttachmentParams{
+			Machine:    names.NewMachineTag("0"),
+			InstanceId: "inst-ance",
+		},
+		Path: testMountPoint,
+		Attributes: map[string]interface{}{
+			"quota":      "1G",
+			"quota-user": "alice",
+		},
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, jc.ErrorIsNil)
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsQuotaUnsupported(c *gc.C) {
+	s.testCreateFilesystemsQuotaInvalid(c, map[string]interface{}{
+		"filesystem-type": "xfs",
+		"quota":           "1G",
+		"quota-user":      "alice",
+	}, "quotas on xfs filesystems not supported")
+}
+
+func (s *managedfsSuite) TestCreateFilesystemsQuotaInvalid(c *gc.C) {
+	s.testCreateFilesystemsQuotaInvalid(c, map[string]interface{}{
+		"quota": "1G",
+	}, `"quota-user" must be specified with "quota"`)
+	s.testCreateFilesystemsQuotaInvalid(c, map[string]interface{}{
+		"quota":      "lots",
+		"quota-user": "alice",
+	}, `parsing quota: .*`)
+	s.testCreateFilesystemsQuotaInvalid(c, map[string]interface{}{
+		"quota":      "1G",
+		"quota-user": "-alice",
+	}, `quota-user "-alice" not valid`)
+}
+
+func (s *managedfsSuite) testCreateFilesystemsQuotaInvalid(c *gc.C, attrs map[string]interface{}, expect string) {
+	source := s.initSource(c)
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		HardwareId: "weetbix",
+		Size:       2048,
+	}
+	results, err := source.CreateFilesystems([]storage.FilesystemParams{{
+		Tag:        names.NewFilesystemTag("0/0"),
+		Volume:     names.NewVolumeTag("0"),
+		Size:       2048,
+		Attributes: attrs,
+	}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results[0].Error, gc.ErrorMatches, expect)
+}
+
+func (s *managedfsSuite) TestIsAttachedMounted(c *gc.C) {
+	s.testIsAttached(c, "/dev/sda1", true)
+}
+
+func (s *managedfsSuite) TestIsAttachedNotMounted(c *gc.C) {
+	s.testIsAttached(c, "/same/as/rootfs", false)
+}
+
+func (s *managedfsSuite) TestIsAttachedWrongDevice(c *gc.C) {
+	s.testIsAttached(c, "/dev/sdb1", false)
+}
+
+func (s *managedfsSuite) testIsAttached(c *gc.C, mountSource string, expect bool) {
+	source := s.initSource(c)
+	cmd := s.commands.expect("df", "--output=source", "/in/the")
+	cmd.respond("headers\n/same/as/rootfs", nil)
+	cmd = s.commands.expect("df", "--output=source", "/in/the/place")
+	cmd.respond("headers\n"+mountSource, nil)
+
+	s.blockDevices[names.NewVolumeTag("0")] = storage.BlockDevice{
+		DeviceName: "sda",
+		HardwareId: "capncrunch",
+		Size:       2,
+	}
+	s.filesystems[names.NewFilesystemTag("0/0")] = storage.Filesystem{
+		Tag:    names.NewFilesystemTag("0/0"),
+		Volume: names.NewVolumeTag("0"),
+	}
+	attached, err := provider.IsAttached(source, names.NewFilesystemTag("0/0"), "/in/the/place")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(attached, gc.Equals, expect)
+}
+
+func (s *managedfsSuite) TestIsAttachedUnknownFilesystem(c *gc.C) {
+	source := s.initSource(c)
+	_, err := provider.IsAttached(source, names.NewFilesystemTag("0/0"), "/in/the/place")
+	c.Assert(err, gc.ErrorMatches, "filesystem 0/0 not found")
 }
 
 func (s *managedfsSuite) TestDetachFilesystems(c *gc.C) {
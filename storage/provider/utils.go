@@ -6,6 +6,7 @@ package provider
 import (
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -33,3 +34,18 @@ func logAndExec(cmd string, args ...string) (string, error) {
 	}
 	return string(output), err
 }
+
+// exitCode returns the exit code of the command that failed with
+// the given error, as returned by logAndExec. The boolean result
+// is false if the error does not record an exit code.
+func exitCode(err error) (int, bool) {
+	exitErr, ok := errors.Cause(err).(*exec.ExitError)
+	if !ok {
+		return 0, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0, false
+	}
+	return status.ExitStatus(), true
+}
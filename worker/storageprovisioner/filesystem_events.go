@@ -438,6 +438,8 @@ func filesystemFromParams(in params.Filesystem) (storage.Filesystem, error) {
 		storage.FilesystemInfo{
 			in.Info.FilesystemId,
 			in.Info.Size,
+			in.Info.UUID,
+			in.Info.Label,
 		},
 	}, nil
 }
@@ -484,5 +486,6 @@ func filesystemAttachmentParamsFromParams(in params.FilesystemAttachmentParams)
 		Filesystem:   filesystemTag,
 		FilesystemId: in.FilesystemId,
 		Path:         in.MountPoint,
+		Attributes:   in.Attributes,
 	}, nil
 }
@@ -471,6 +471,8 @@ func filesystemsFromStorage(in []storage.Filesystem) []params.Filesystem {
 				f.FilesystemId,
 				"", // pool
 				f.Size,
+				f.UUID,
+				f.Label,
 			},
 		}
 		if f.Volume != (names.VolumeTag{}) {
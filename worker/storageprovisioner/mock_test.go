@@ -244,6 +244,10 @@ type mockFilesystemAccessor struct {
 	provisionedFilesystems map[string]params.Filesystem
 	provisionedAttachments map[params.MachineStorageId]params.FilesystemAttachment
 
+	// attachmentAttributes holds the pool attributes
+	// returned with filesystem attachment params.
+	attachmentAttributes map[string]interface{}
+
 	setFilesystemInfo           func([]params.Filesystem) ([]params.ErrorResult, error)
 	setFilesystemAttachmentInfo func([]params.FilesystemAttachment) ([]params.ErrorResult, error)
 }
@@ -330,6 +334,7 @@ func (f *mockFilesystemAccessor) FilesystemAttachmentParams(ids []params.Machine
 			InstanceId:    string(instanceId),
 			Provider:      "dummy",
 			ReadOnly:      true,
+			Attributes:    f.attachmentAttributes,
 		}})
 	}
 	return result, nil
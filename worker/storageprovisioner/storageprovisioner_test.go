@@ -980,6 +980,51 @@ func (s *storageProvisionerSuite) TestFilesystemAttachmentAdded(c *gc.C) {
 	assertNoEvent(c, filesystemAttachmentInfoSet, "filesystem attachment info set")
 }
 
+func (s *storageProvisionerSuite) TestFilesystemAttachmentPoolAttributes(c *gc.C) {
+	filesystemAccessor := newMockFilesystemAccessor()
+	filesystemAccessor.provisionedFilesystems["filesystem-1"] = params.Filesystem{
+		FilesystemTag: "filesystem-1",
+		Info: params.FilesystemInfo{
+			FilesystemId: "fs-123",
+		},
+	}
+	filesystemAccessor.provisionedMachines["machine-1"] = instance.Id("already-provisioned-1")
+	filesystemAccessor.attachmentAttributes = map[string]interface{}{
+		"encrypt":          true,
+		"encrypt-key-file": "/etc/juju/fs.key",
+	}
+
+	attachArgs := make(chan interface{}, 1)
+	s.provider.attachFilesystemsFunc = func(args []storage.FilesystemAttachmentParams) ([]storage.AttachFilesystemsResult, error) {
+		attachArgs <- args
+		return []storage.AttachFilesystemsResult{{
+			FilesystemAttachment: &storage.FilesystemAttachment{
+				args[0].Filesystem,
+				args[0].Machine,
+				storage.FilesystemAttachmentInfo{
+					Path: "/srv/fs-123",
+				},
+			},
+		}}, nil
+	}
+
+	args := &workerArgs{filesystems: filesystemAccessor, registry: s.registry}
+	worker := newStorageProvisioner(c, args)
+	defer func() { c.Assert(worker.Wait(), gc.IsNil) }()
+	defer worker.Kill()
+
+	filesystemAccessor.attachmentsWatcher.changes <- []watcher.MachineStorageId{{
+		MachineTag: "machine-1", AttachmentTag: "filesystem-1",
+	}}
+	filesystemAccessor.filesystemsWatcher.changes <- []string{"1"}
+
+	// The pool attributes are passed to the storage
+	// provider with the attachment parameters.
+	attachments := waitChannel(c, attachArgs, "waiting for filesystems to be attached").([]storage.FilesystemAttachmentParams)
+	c.Assert(attachments, gc.HasLen, 1)
+	c.Assert(attachments[0].Attributes, jc.DeepEquals, filesystemAccessor.attachmentAttributes)
+}
+
 func (s *storageProvisionerSuite) TestCreateVolumeBackedFilesystem(c *gc.C) {
 	filesystemInfoSet := make(chan interface{})
 	filesystemAccessor := newMockFilesystemAccessor()
@@ -1097,6 +1142,61 @@ func (s *storageProvisionerSuite) TestAttachVolumeBackedFilesystem(c *gc.C) {
 	}})
 }
 
+func (s *storageProvisionerSuite) TestAttachVolumeBackedFilesystemRecordedInfo(c *gc.C) {
+	// The filesystem was created before the worker restarted; the
+	// information recorded in state must be given to the source.
+	filesystems := make(chan interface{}, 1)
+	filesystemAccessor := newMockFilesystemAccessor()
+	filesystemAccessor.setFilesystemAttachmentInfo = func(attachments []params.FilesystemAttachment) ([]params.ErrorResult, error) {
+		filesystems <- s.managedFilesystemSource.filesystems[names.NewFilesystemTag("0/0")]
+		return nil, nil
+	}
+
+	args := &workerArgs{
+		scope:       names.NewMachineTag("0"),
+		filesystems: filesystemAccessor,
+		registry:    s.registry,
+	}
+	worker := newStorageProvisioner(c, args)
+	defer func() { c.Assert(worker.Wait(), gc.IsNil) }()
+	defer worker.Kill()
+
+	filesystemAccessor.provisionedFilesystems["filesystem-0-0"] = params.Filesystem{
+		FilesystemTag: "filesystem-0-0",
+		VolumeTag:     "volume-0-0",
+		Info: params.FilesystemInfo{
+			FilesystemId: "whatever",
+			Size:         123,
+			UUID:         "0b56138b-6124-4ec4-a7a3-7c503516a65c",
+			Label:        "juju-data",
+		},
+	}
+	filesystemAccessor.provisionedMachines["machine-0"] = instance.Id("already-provisioned-0")
+
+	args.volumes.blockDevices[params.MachineStorageId{
+		MachineTag:    "machine-0",
+		AttachmentTag: "volume-0-0",
+	}] = storage.BlockDevice{
+		DeviceName: "xvdf1",
+		Size:       123,
+	}
+	filesystemAccessor.attachmentsWatcher.changes <- []watcher.MachineStorageId{{
+		MachineTag:    "machine-0",
+		AttachmentTag: "filesystem-0-0",
+	}}
+	filesystemAccessor.filesystemsWatcher.changes <- []string{"0/0"}
+
+	filesystem := waitChannel(
+		c, filesystems, "waiting for filesystem attachment info to be set",
+	).(storage.Filesystem)
+	c.Assert(filesystem.FilesystemInfo, jc.DeepEquals, storage.FilesystemInfo{
+		FilesystemId: "whatever",
+		Size:         123,
+		UUID:         "0b56138b-6124-4ec4-a7a3-7c503516a65c",
+		Label:        "juju-data",
+	})
+}
+
 func (s *storageProvisionerSuite) TestResourceTags(c *gc.C) {
 	volumeInfoSet := make(chan interface{})
 	volumeAccessor := newMockVolumeAccessor()